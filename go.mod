@@ -4,6 +4,7 @@ go 1.14
 
 require (
 	github.com/cucumber/godog v0.11.0
+	github.com/cucumber/messages-go/v10 v10.0.3
 	github.com/hashicorp/go-hclog v0.15.0 // indirect
 	github.com/markbates/pkger v0.17.1
 	github.com/probr/probr-sdk v0.1.5
@@ -12,6 +13,9 @@ require (
 	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	k8s.io/api v0.19.6
+	k8s.io/apimachinery v0.19.6
+	k8s.io/client-go v0.19.6
+	sigs.k8s.io/yaml v1.2.0
 )
 
 // For Development Only
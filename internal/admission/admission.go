@@ -0,0 +1,144 @@
+// Package admission identifies the admission control that denied a request, so that a denial
+// can be attributed to a security policy rather than to a quota, a missing image or an unrelated webhook,
+// and reports the security-relevant fields changed by mutating admission controls
+package admission
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Controllers reported in DenialReason.Controller
+const (
+	PodSecurity       = "PodSecurity"
+	PodSecurityPolicy = "PodSecurityPolicy"
+	Gatekeeper        = "Gatekeeper"
+	Kyverno           = "Kyverno"
+	Webhook           = "Webhook" // Any other validating or mutating admission webhook
+	ResourceQuota     = "ResourceQuota"
+	LimitRange        = "LimitRange"
+	ImagePull         = "ImagePull"
+	Unknown           = "Unknown"
+)
+
+// DenialReason describes which admission control denied a request, and the policy responsible
+type DenialReason struct {
+	Controller string
+	Policy     string   `json:",omitempty"` // PodSecurity level, Gatekeeper constraint, Kyverno policy, webhook or quota name
+	Rules      []string `json:",omitempty"` // Violated checks, Kyverno rules or constraint messages
+	StatusCode int32    `json:",omitempty"`
+	Message    string
+}
+
+var (
+	podSecurity   = regexp.MustCompile(`violates PodSecurity "([^"]+)": (.*)`)
+	psp           = regexp.MustCompile(`(?:PodSecurityPolicy: unable to admit pod|unable to validate against any pod security policy): (.*)`)
+	webhook       = regexp.MustCompile(`(?s)admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+	constraint    = regexp.MustCompile(`\[([^\]]+)\]\s*([^\n]*)`)
+	kyvernoPolicy = regexp.MustCompile(`^([a-z0-9][-a-z0-9.]*):\s*$`)
+	kyvernoRule   = regexp.MustCompile(`^\s+([-a-z0-9.]+):\s*(.*)$`)
+	quota         = regexp.MustCompile(`(?:exceeded|failed) quota: ([^,:\s]+)`)
+)
+
+// ParseDenial returns the reason for the denial described by the error, or nil if there is no error
+func ParseDenial(err error) *DenialReason {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	reason := &DenialReason{Controller: Unknown, Message: message}
+	if status, ok := err.(apierrors.APIStatus); ok {
+		reason.StatusCode = status.Status().Code
+	}
+
+	if match := podSecurity.FindStringSubmatch(message); match != nil {
+		reason.Controller, reason.Policy, reason.Rules = PodSecurity, match[1], []string{match[2]}
+	} else if match := psp.FindStringSubmatch(message); match != nil {
+		reason.Controller, reason.Rules = PodSecurityPolicy, []string{match[1]}
+	} else if match := webhook.FindStringSubmatch(message); match != nil {
+		parseWebhook(reason, match[1], match[2])
+	} else if match := quota.FindStringSubmatch(message); match != nil {
+		reason.Controller, reason.Policy = ResourceQuota, match[1]
+	} else if strings.Contains(message, "usage per Container") || strings.Contains(message, "usage per Pod") {
+		reason.Controller = LimitRange
+	} else if strings.Contains(message, "ErrImagePull") || strings.Contains(message, "ImagePullBackOff") {
+		reason.Controller = ImagePull
+	}
+	return reason
+}
+
+func parseWebhook(reason *DenialReason, name, details string) {
+	switch {
+	case strings.Contains(name, "gatekeeper"):
+		reason.Controller = Gatekeeper
+		var constraints []string
+		for _, match := range constraint.FindAllStringSubmatch(details, -1) {
+			constraints = append(constraints, match[1])
+			reason.Rules = append(reason.Rules, strings.TrimSpace(match[2]))
+		}
+		reason.Policy = strings.Join(constraints, ", ")
+	case strings.Contains(name, "kyverno"):
+		reason.Controller = Kyverno
+		var policies []string
+		for _, line := range strings.Split(details, "\n") {
+			if match := kyvernoPolicy.FindStringSubmatch(line); match != nil {
+				policies = append(policies, match[1])
+			} else if match := kyvernoRule.FindStringSubmatch(line); match != nil && len(policies) > 0 {
+				reason.Rules = append(reason.Rules, match[1])
+			}
+		}
+		reason.Policy = strings.Join(policies, ", ")
+	default:
+		reason.Controller, reason.Policy = Webhook, name
+		if details = strings.TrimSpace(details); details != "" {
+			reason.Rules = []string{details}
+		}
+	}
+}
+
+// String summarises the reason for use in step traces and errors
+func (d *DenialReason) String() string {
+	if d == nil {
+		return "no denial"
+	}
+	if d.Policy == "" {
+		return d.Controller
+	}
+	return fmt.Sprintf("%s (%s)", d.Controller, d.Policy)
+}
+
+// Matches returns true if the denial came from the expected control, given either as a controller
+// such as "Gatekeeper", or as a controller and policy such as "Kyverno/disallow-privileged-containers"
+func (d *DenialReason) Matches(expected string) bool {
+	if d == nil {
+		return false
+	}
+	parts := strings.SplitN(expected, "/", 2)
+	if !strings.EqualFold(parts[0], d.Controller) {
+		return false
+	}
+	if len(parts) == 1 {
+		return true
+	}
+	for _, policy := range strings.Split(d.Policy, ", ") {
+		if policy == parts[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Accepted returns true if the denial came from one of the AdmissionControllers.
+// Denials for any other reason do not show that a security control is in place.
+func (d *DenialReason) Accepted() bool {
+	for _, expected := range config.Vars.ServicePacks.Kubernetes.AdmissionControllers {
+		if d.Matches(expected) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package admission
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Mutation is a security-relevant field whose value in the created pod differs from the requested pod.
+// An empty value means the field was not set.
+type Mutation struct {
+	Field     string
+	Requested string
+	Created   string
+}
+
+// Mutations compares the security-relevant fields of the requested and created pods, such as
+// host namespaces, security contexts, capabilities, seccomp and AppArmor settings and images.
+// Containers are compared by name, so containers injected at admission are also reported.
+func Mutations(requested, created *apiv1.Pod) (mutations []Mutation) {
+	if requested == nil || created == nil {
+		return nil
+	}
+	before, after := securityFields(requested), securityFields(created)
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+	for field := range fields {
+		if before[field] != after[field] {
+			mutations = append(mutations, Mutation{Field: field, Requested: before[field], Created: after[field]})
+		}
+	}
+	sort.Slice(mutations, func(i, j int) bool { return mutations[i].Field < mutations[j].Field })
+	return
+}
+
+// String summarises the mutation for use in step traces and errors
+func (m Mutation) String() string {
+	return fmt.Sprintf("%s changed from %s to %s", m.Field, describe(m.Requested), describe(m.Created))
+}
+
+// MutatedFields returns the mutations to fields whose path contains the fragment
+func MutatedFields(mutations []Mutation, fragment string) (matched []Mutation) {
+	for _, mutation := range mutations {
+		if strings.Contains(mutation.Field, fragment) {
+			matched = append(matched, mutation)
+		}
+	}
+	return
+}
+
+// securityFields flattens the security-relevant fields of the pod into a map of field paths to values, omitting unset fields
+func securityFields(pod *apiv1.Pod) map[string]string {
+	fields := make(map[string]string)
+	set := func(field, value string) {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+
+	for key, value := range pod.ObjectMeta.Annotations {
+		if strings.HasPrefix(key, "seccomp.security.alpha.kubernetes.io/") || strings.HasPrefix(key, "container.apparmor.security.beta.kubernetes.io/") {
+			set(fmt.Sprintf("metadata.annotations[%s]", key), value)
+		}
+	}
+
+	spec := pod.Spec
+	set("spec.hostPID", boolValue(spec.HostPID))
+	set("spec.hostIPC", boolValue(spec.HostIPC))
+	set("spec.hostNetwork", boolValue(spec.HostNetwork))
+	set("spec.automountServiceAccountToken", boolPointer(spec.AutomountServiceAccountToken))
+	if sc := spec.SecurityContext; sc != nil {
+		set("spec.securityContext.runAsUser", intPointer(sc.RunAsUser))
+		set("spec.securityContext.runAsGroup", intPointer(sc.RunAsGroup))
+		set("spec.securityContext.runAsNonRoot", boolPointer(sc.RunAsNonRoot))
+		set("spec.securityContext.fsGroup", intPointer(sc.FSGroup))
+		set("spec.securityContext.seccompProfile", seccompProfile(sc.SeccompProfile))
+		var groups []string
+		for _, group := range sc.SupplementalGroups {
+			groups = append(groups, strconv.FormatInt(group, 10))
+		}
+		set("spec.securityContext.supplementalGroups", strings.Join(groups, ","))
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			set(fmt.Sprintf("spec.volumes[%s].hostPath", volume.Name), volume.HostPath.Path)
+		}
+	}
+
+	containers := map[string][]apiv1.Container{"initContainers": spec.InitContainers, "containers": spec.Containers}
+	for kind, list := range containers {
+		for _, container := range list {
+			prefix := fmt.Sprintf("spec.%s[%s]", kind, container.Name)
+			set(prefix+".image", container.Image)
+			sc := container.SecurityContext
+			if sc == nil {
+				continue
+			}
+			set(prefix+".securityContext.privileged", boolPointer(sc.Privileged))
+			set(prefix+".securityContext.allowPrivilegeEscalation", boolPointer(sc.AllowPrivilegeEscalation))
+			set(prefix+".securityContext.readOnlyRootFilesystem", boolPointer(sc.ReadOnlyRootFilesystem))
+			set(prefix+".securityContext.runAsUser", intPointer(sc.RunAsUser))
+			set(prefix+".securityContext.runAsGroup", intPointer(sc.RunAsGroup))
+			set(prefix+".securityContext.runAsNonRoot", boolPointer(sc.RunAsNonRoot))
+			set(prefix+".securityContext.seccompProfile", seccompProfile(sc.SeccompProfile))
+			if sc.ProcMount != nil {
+				set(prefix+".securityContext.procMount", string(*sc.ProcMount))
+			}
+			if sc.Capabilities != nil {
+				set(prefix+".securityContext.capabilities.add", capabilities(sc.Capabilities.Add))
+				set(prefix+".securityContext.capabilities.drop", capabilities(sc.Capabilities.Drop))
+			}
+		}
+	}
+	return fields
+}
+
+func describe(value string) string {
+	if value == "" {
+		return "unset"
+	}
+	return "'" + value + "'"
+}
+
+func boolValue(value bool) string {
+	if !value {
+		return "" // false is the default, so is equivalent to not being set
+	}
+	return "true"
+}
+
+func boolPointer(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func intPointer(value *int64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatInt(*value, 10)
+}
+
+func seccompProfile(profile *apiv1.SeccompProfile) string {
+	if profile == nil {
+		return ""
+	}
+	if profile.LocalhostProfile != nil {
+		return string(profile.Type) + "/" + *profile.LocalhostProfile
+	}
+	return string(profile.Type)
+}
+
+func capabilities(values []apiv1.Capability) string {
+	var names []string
+	for _, value := range values {
+		names = append(names, string(value))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
@@ -79,7 +79,7 @@ func (ctx *kubernetes) setEnvAndDefaults() {
 	// 3. Default value to set if flags, vars file, and env have not provided a value
 
 	setter.SetVar(&ctx.KeepPods, "PROBR_KEEP_PODS", "false")
-	setter.SetVar(&ctx.KubeConfigPath, "KUBE_CONFIG", getDefaultKubeConfigPath())
+	setter.SetVar(&ctx.KubeConfigPath, "KUBE_CONFIG", DefaultKubeConfigPath())
 	setter.SetVar(&ctx.KubeContext, "KUBE_CONTEXT", "")
 	setter.SetVar(&ctx.SystemClusterRoles, "", []string{"system:", "aks", "cluster-admin", "policy-agent"})
 	setter.SetVar(&ctx.AuthorisedContainerImage, "PROBR_AUTHORISED_IMAGE", "")
@@ -93,9 +93,45 @@ func (ctx *kubernetes) setEnvAndDefaults() {
 	setter.SetVar(&ctx.ProbeNamespace, "PROBR_K8S_PROBE_NAMESPACE", "probr-general-test-ns")
 	setter.SetVar(&ctx.Azure.DefaultNamespaceAIB, "DEFAULT_NS_AZURE_IDENTITY_BINDING", "probr-aib")
 	setter.SetVar(&ctx.Azure.IdentityNamespace, "PROBR_K8S_AZURE_IDENTITY_NAMESPACE", "kube-system")
+	setter.SetVar(&ctx.WaiversPath, "PROBR_WAIVERS_PATH", "")
+	setter.SetVar(&ctx.FailOnSeverity, "PROBR_FAIL_ON_SEVERITY", "low")
+	setter.SetVar(&ctx.BaselinePath, "PROBR_BASELINE_PATH", "")
+	setter.SetVar(&ctx.KubeContexts, "KUBE_CONTEXTS", []string{})
+	setter.SetVar(&ctx.KubeConfigDir, "KUBE_CONFIG_DIR", "")
+	setter.SetVar(&ctx.InCluster, "PROBR_IN_CLUSTER", "auto")
+	setter.SetVar(&ctx.RunInterval, "PROBR_RUN_INTERVAL", "1h")
+	setter.SetVar(&ctx.MetricsAddress, "PROBR_METRICS_ADDRESS", ":9090")
+	setter.SetVar(&ctx.PolicyReports, "PROBR_POLICY_REPORTS", "false")
+	setter.SetVar(&ctx.ConnectionMode, "PROBR_CONNECTION_MODE", "live")
+	setter.SetVar(&ctx.FixturePath, "PROBR_FIXTURE_PATH", "probr-fixture.json")
+	setter.SetVar(&ctx.CustomFeaturesDir, "PROBR_CUSTOM_FEATURES_DIR", "")
+	setter.SetVar(&ctx.FeatureOverrideDir, "PROBR_FEATURE_OVERRIDE_DIR", "")
+	setter.SetVar(&ctx.ToolkitImage, "PROBR_TOOLKIT_IMAGE", "")
+	setter.SetVar(&ctx.ToolkitSourceDir, "PROBR_TOOLKIT_SOURCE_DIR", "/bin")
+	setter.SetVar(&ctx.EgressProxy, "PROBR_EGRESS_PROXY", "")
+	setter.SetVar(&ctx.EgressTestURLs, "PROBR_EGRESS_TEST_URLS", []string{})
+	setter.SetVar(&ctx.EgressProxyTestURLs, "PROBR_EGRESS_PROXY_TEST_URLS", []string{})
+	setter.SetVar(&ctx.AdmissionControllers, "PROBR_ADMISSION_CONTROLLERS", []string{"PodSecurity", "PodSecurityPolicy", "Gatekeeper", "Kyverno"})
+	setter.SetVar(&ctx.PodSecurityMinimumLevel, "PROBR_POD_SECURITY_MINIMUM_LEVEL", "baseline")
+	setter.SetVar(&ctx.PodSecurityExemptNamespaces, "PROBR_POD_SECURITY_EXEMPT_NAMESPACES", []string{"kube-system", "kube-public", "kube-node-lease"})
+	if len(ctx.EgressDenialSignatures) == 0 {
+		ctx.EgressDenialSignatures = defaultDenialSignatures()
+	}
+}
+
+// defaultDenialSignatures identifies requests blocked by Azure Firewall, or that could not connect at all
+func defaultDenialSignatures() []denialSignature {
+	return []denialSignature{
+		{Name: "azure-firewall", BodyPattern: "Action: Deny"},
+		{Name: "dns-resolution-failed", ExitCodes: []int{6}},
+		{Name: "connection-failed", ExitCodes: []int{7}},
+		{Name: "timeout", ExitCodes: []int{28}},
+		{Name: "tls-handshake-failed", ExitCodes: []int{35}},
+	}
 }
 
-func getDefaultKubeConfigPath() string {
+// DefaultKubeConfigPath returns the kubeconfig path used when none has been configured
+func DefaultKubeConfigPath() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".kube", "config")
 }
@@ -14,17 +14,57 @@ type servicePacks struct {
 
 type kubernetes struct {
 	kc.Kubernetes                     `yaml:",inline"`
-	SystemClusterRoles                []string `yaml:"SystemClusterRoles"`
-	UnauthorisedContainerImage        string   `yaml:"UnauthorisedContainerImage"`
-	ContainerRequiredDropCapabilities []string `yaml:"ContainerRequiredDropCapabilities"`
-	ContainerAllowedAddCapabilities   []string `yaml:"ContainerAllowedAddCapabilities"`
-	ApprovedVolumeTypes               []string `yaml:"ApprovedVolumeTypes"`
-	UnapprovedHostPort                string   `yaml:"UnapprovedHostPort"`
-	SystemNamespace                   string   `yaml:"SystemNamespace"`
-	DashboardPodNamePrefix            string   `yaml:"DashboardPodNamePrefix"`
-	Azure                             k8sAzure `yaml:"Azure"`
-	TagInclusions                     []string `yaml:"TagInclusions"`
-	TagExclusions                     []string `yaml:"TagExclusions"`
+	SystemClusterRoles                []string                 `yaml:"SystemClusterRoles"`
+	UnauthorisedContainerImage        string                   `yaml:"UnauthorisedContainerImage"`
+	ContainerRequiredDropCapabilities []string                 `yaml:"ContainerRequiredDropCapabilities"`
+	ContainerAllowedAddCapabilities   []string                 `yaml:"ContainerAllowedAddCapabilities"`
+	ApprovedVolumeTypes               []string                 `yaml:"ApprovedVolumeTypes"`
+	UnapprovedHostPort                string                   `yaml:"UnapprovedHostPort"`
+	SystemNamespace                   string                   `yaml:"SystemNamespace"`
+	DashboardPodNamePrefix            string                   `yaml:"DashboardPodNamePrefix"`
+	Azure                             k8sAzure                 `yaml:"Azure"`
+	TagInclusions                     []string                 `yaml:"TagInclusions"`
+	TagExclusions                     []string                 `yaml:"TagExclusions"`
+	WaiversPath                       string                   `yaml:"WaiversPath"`
+	FailOnSeverity                    string                   `yaml:"FailOnSeverity"`
+	BaselinePath                      string                   `yaml:"BaselinePath"`
+	KubeContexts                      []string                 `yaml:"KubeContexts"`
+	KubeConfigDir                     string                   `yaml:"KubeConfigDir"`
+	InCluster                         string                   `yaml:"InCluster"`
+	RunInterval                       string                   `yaml:"RunInterval"`
+	MetricsAddress                    string                   `yaml:"MetricsAddress"`
+	PolicyReports                     string                   `yaml:"PolicyReports"`
+	ConnectionMode                    string                   `yaml:"ConnectionMode"`
+	FixturePath                       string                   `yaml:"FixturePath"`
+	CustomFeaturesDir                 string                   `yaml:"CustomFeaturesDir"`
+	FeatureOverrideDir                string                   `yaml:"FeatureOverrideDir"`
+	ToolkitImage                      string                   `yaml:"ToolkitImage"`
+	ToolkitSourceDir                  string                   `yaml:"ToolkitSourceDir"`
+	ExternalChecks                    map[string]externalCheck `yaml:"ExternalChecks"`
+	EgressDenialSignatures            []denialSignature        `yaml:"EgressDenialSignatures"`
+	EgressProxy                       string                   `yaml:"EgressProxy"`
+	EgressTestURLs                    []string                 `yaml:"EgressTestURLs"`
+	EgressProxyTestURLs               []string                 `yaml:"EgressProxyTestURLs"`
+	AdmissionControllers              []string                 `yaml:"AdmissionControllers"`
+	PodSecurityMinimumLevel           string                   `yaml:"PodSecurityMinimumLevel"`
+	PodSecurityExemptNamespaces       []string                 `yaml:"PodSecurityExemptNamespaces"`
+}
+
+// denialSignature identifies the response of an egress control that blocked a request.
+// Every criterion provided must match; criteria that are not provided are ignored.
+type denialSignature struct {
+	Name            string `yaml:"Name"`
+	BodyPattern     string `yaml:"BodyPattern"`     // Regular expression matched against the response body
+	HTTPStatusCodes []int  `yaml:"HTTPStatusCodes"` // Status codes returned by a block page
+	ExitCodes       []int  `yaml:"ExitCodes"`       // curl exit codes
+	TLSErrorPattern string `yaml:"TLSErrorPattern"` // Regular expression matched against curl's error output
+}
+
+// externalCheck describes an organisation-specific command that can be executed by the 'external check' step
+type externalCheck struct {
+	Command string   `yaml:"Command"`
+	Args    []string `yaml:"Args"`
+	Timeout string   `yaml:"Timeout"`
 }
 
 // K8sAzure contains Azure-specific options for the Kubernetes service pack
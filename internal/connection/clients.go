@@ -0,0 +1,31 @@
+package connection
+
+import (
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-sdk/utils"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// RestConfig builds a client config for the cluster described by config.Vars.ServicePacks.Kubernetes,
+// for use by clients that are not provided by the SDK connection
+func RestConfig() (*rest.Config, error) {
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: config.Vars.ServicePacks.Kubernetes.KubeConfigPath},
+		&clientcmd.ConfigOverrides{CurrentContext: config.Vars.ServicePacks.Kubernetes.KubeContext})
+	restConfig, err := loader.ClientConfig()
+	if err != nil {
+		return nil, utils.ReformatError("Failed to build client config: %v", err)
+	}
+	return restConfig, nil
+}
+
+// DynamicClient returns a client that can be used to manage custom resources in the connected cluster
+func DynamicClient() (dynamic.Interface, error) {
+	restConfig, err := RestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(restConfig)
+}
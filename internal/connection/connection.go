@@ -5,17 +5,83 @@ import (
 
 	"github.com/probr/probr-pack-kubernetes/internal/config"
 	"github.com/probr/probr-sdk/providers/kubernetes/connection"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/version"
 )
 
+// Connection describes the Kubernetes API operations used by the probes.
+// Probes should only depend on this interface, so that steps can be executed against a fake cluster.
+type Connection interface {
+	ClusterIsDeployed() error
+	CreatePodFromObject(pod *apiv1.Pod, probeName string) (*apiv1.Pod, error)
+	DeletePodIfExists(podName, namespace, probeName string) error
+	ExecCommand(command, namespace, podName string) (status int, stdout string, stderr string, err error)
+	GetPodIPs(namespace, podName string) (podIP string, hostIP string, err error)
+	GetPodsByNamespace(namespace string) (*apiv1.PodList, error)
+	GetAllPods() (*apiv1.PodList, error)
+	GetAllDeployments() (*appsv1.DeploymentList, error)
+	GetAllServices() (*apiv1.ServiceList, error)
+	GetNamespaces() (*apiv1.NamespaceList, error)
+	GetClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error)
+	GetAllRoleBindings() (*rbacv1.RoleBindingList, error)
+	ServerVersion() (*version.Info, error)
+}
+
 // TODO: Decide whether this 'connection.state' is the best naming convention
 
 // State is a stateful Kubernetes API wrapper
-var State *connection.Conn
+var State Connection
+
+// Version is the server version reported by the cluster when connecting, or nil if it could not be read
+var Version *version.Info
 
 // Connect initializes connection.State using values from config.Vars.ServicePacks.Kubernetes
 func Connect() {
+	if config.Vars.ServicePacks.Kubernetes.ConnectionMode == ReplayMode {
+		replayer, err := NewReplayer(config.Vars.ServicePacks.Kubernetes.FixturePath)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		State = replayer
+		readServerVersion()
+		return
+	}
+	if InClusterMode() {
+		kubeConfigPath, err := writeInClusterKubeConfig()
+		if err != nil {
+			log.Printf("[ERROR] Failed to configure in-cluster connection: %v", err)
+		} else {
+			log.Print("[INFO] Using in-cluster service account credentials")
+			config.Vars.ServicePacks.Kubernetes.KubeConfigPath = kubeConfigPath
+			config.Vars.ServicePacks.Kubernetes.KubeContext = ""
+		}
+	}
 	log.Printf("[DEBUG] Initializing connection with namespace '%s' and context '%s' using kubeconfig: %s",
-		config.Vars.ServicePacks.Kubernetes.KubeConfigPath, config.Vars.ServicePacks.Kubernetes.KubeContext, config.Vars.ServicePacks.Kubernetes.ProbeNamespace)
-	State = connection.NewConnection(config.Vars.ServicePacks.Kubernetes.KubeConfigPath, config.Vars.ServicePacks.Kubernetes.KubeContext, config.Vars.ServicePacks.Kubernetes.ProbeNamespace)
+		config.Vars.ServicePacks.Kubernetes.ProbeNamespace, config.Vars.ServicePacks.Kubernetes.KubeContext, config.Vars.ServicePacks.Kubernetes.KubeConfigPath)
+	kubeConfigPath, err := contextKubeConfig(config.Vars.ServicePacks.Kubernetes.KubeConfigPath, config.Vars.ServicePacks.Kubernetes.KubeContext)
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	// The context is already the current-context of the kubeconfig, so none is passed to the SDK
+	State = newLive(connection.NewConnection(kubeConfigPath, "", config.Vars.ServicePacks.Kubernetes.ProbeNamespace))
+	if config.Vars.ServicePacks.Kubernetes.ConnectionMode == RecordMode {
+		log.Printf("[INFO] Recording cluster interactions to %s", config.Vars.ServicePacks.Kubernetes.FixturePath)
+		State = NewRecorder(State)
+	}
 	log.Print("[DEBUG] Initialized Kubernetes API connection")
+	readServerVersion()
+}
+
+// readServerVersion sets Version using the current State
+func readServerVersion() {
+	info, err := State.ServerVersion()
+	if err != nil {
+		log.Printf("[WARN] Failed to read the cluster's server version, so version-gated scenarios will not be skipped: %v", err)
+		Version = nil
+		return
+	}
+	log.Printf("[INFO] Connected to Kubernetes %s", info.GitVersion)
+	Version = info
 }
@@ -0,0 +1,300 @@
+// Package fake provides an implementation of connection.Connection backed by the client-go fake Clientset, so that
+// probe steps can be executed without a live cluster. Admission decisions and command results are scripted by the caller.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-sdk/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// HostIP is reported for every fake pod. Pods using hostNetwork also report it as their pod IP.
+const HostIP = "192.168.0.10"
+
+// DefaultServerVersion is reported by ServerVersion until SetServerVersion is called
+const DefaultServerVersion = "v1.19.6"
+
+const requestTimeout = 10 * time.Second
+
+// ExecResult describes the scripted outcome of a command executed inside a pod
+type ExecResult struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Err      error // Simulates a failure to reach the pod, rather than a command failure
+}
+
+// AdmissionRule denies the creation of any pod that it matches
+type AdmissionRule struct {
+	Reason string
+	Match  func(pod *apiv1.Pod) bool
+}
+
+type execScript struct {
+	command *regexp.Regexp
+	result  ExecResult
+}
+
+var _ connection.Connection = (*Cluster)(nil)
+
+// Cluster is a fake cluster that meets the connection.Connection interface. Objects are held by a client-go fake
+// Clientset, with a reactor applying the scripted admission rules and mutations when pods are created.
+type Cluster struct {
+	// Clientset holds the objects in the cluster. Tests may add objects or reactors to it directly.
+	Clientset *k8sfake.Clientset
+
+	mutex     sync.Mutex
+	deployErr error
+	admission []AdmissionRule
+	mutations []func(pod *apiv1.Pod)
+	scripts   []execScript
+	podCount  int
+
+	// Executed records every command run in a pod, in the form "namespace/pod: command"
+	Executed []string
+}
+
+// NewCluster returns a fake cluster containing the provided objects, which admits every pod and succeeds every command
+func NewCluster(objects ...runtime.Object) *Cluster {
+	c := &Cluster{
+		Clientset: k8sfake.NewSimpleClientset(objects...),
+	}
+	c.Clientset.PrependReactor("create", "pods", c.admit)
+	c.SetServerVersion(DefaultServerVersion)
+	return c
+}
+
+// SetDeployError makes ClusterIsDeployed return the provided error
+func (c *Cluster) SetDeployError(err error) {
+	c.deployErr = err
+}
+
+// SetServerVersion sets the version reported by ServerVersion, such as "v1.25.3"
+func (c *Cluster) SetServerVersion(gitVersion string) {
+	var major, minor int
+	fmt.Sscanf(gitVersion, "v%d.%d", &major, &minor)
+	c.Clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		Major:      fmt.Sprint(major),
+		Minor:      fmt.Sprint(minor),
+		GitVersion: gitVersion,
+	}
+}
+
+// AddPod adds an existing pod to the fake cluster, such as a pod in a system namespace
+func (c *Cluster) AddPod(pod *apiv1.Pod) {
+	c.add(pod)
+}
+
+// AddDeployment adds an existing deployment to the fake cluster
+func (c *Cluster) AddDeployment(deployment *appsv1.Deployment) {
+	c.add(deployment)
+}
+
+// AddService adds an existing service to the fake cluster
+func (c *Cluster) AddService(service *apiv1.Service) {
+	c.add(service)
+}
+
+// AddNamespace adds an existing namespace to the fake cluster
+func (c *Cluster) AddNamespace(namespace *apiv1.Namespace) {
+	c.add(namespace)
+}
+
+// AddClusterRoleBinding adds an existing cluster role binding to the fake cluster
+func (c *Cluster) AddClusterRoleBinding(binding *rbacv1.ClusterRoleBinding) {
+	c.add(binding)
+}
+
+// AddRoleBinding adds an existing role binding to the fake cluster
+func (c *Cluster) AddRoleBinding(binding *rbacv1.RoleBinding) {
+	c.add(binding)
+}
+
+// Deny adds an admission rule that rejects pods with a 403 Forbidden error
+func (c *Cluster) Deny(reason string, match func(pod *apiv1.Pod) bool) {
+	c.admission = append(c.admission, AdmissionRule{Reason: reason, Match: match})
+}
+
+// Mutate adds a mutating admission step, which may modify any pod that is not denied before it is stored
+func (c *Cluster) Mutate(mutation func(pod *apiv1.Pod)) {
+	c.mutations = append(c.mutations, mutation)
+}
+
+// OnExec scripts the result of any command matching the provided regular expression.
+// Scripts are evaluated in the order they were added; unmatched commands succeed with no output.
+func (c *Cluster) OnExec(commandPattern string, result ExecResult) {
+	c.scripts = append(c.scripts, execScript{command: regexp.MustCompile(commandPattern), result: result})
+}
+
+// ClusterIsDeployed returns the error provided to SetDeployError, if any
+func (c *Cluster) ClusterIsDeployed() error {
+	return c.deployErr
+}
+
+// CreatePodFromObject creates a copy of the pod, which is rejected or mutated by the scripted admission rules
+func (c *Cluster) CreatePodFromObject(pod *apiv1.Pod, probeName string) (*apiv1.Pod, error) {
+	if pod == nil || pod.ObjectMeta.Name == "" || pod.ObjectMeta.Namespace == "" {
+		return nil, fmt.Errorf("one or more of pod (%v), podName or namespace is nil - cannot create POD", pod)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Pods(pod.ObjectMeta.Namespace).Create(ctx, pod.DeepCopy(), metav1.CreateOptions{})
+}
+
+// DeletePodIfExists removes the pod, returning a NotFound error if it does not exist
+func (c *Cluster) DeletePodIfExists(podName, namespace, probeName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Pods(namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+}
+
+// ExecCommand returns the first scripted result matching the command.
+// As with the SDK connection, a non-zero exit code is also returned as an error.
+func (c *Cluster) ExecCommand(command, namespace, podName string) (status int, stdout string, stderr string, err error) {
+	if command == "" {
+		err = utils.ReformatError("Command string not provided to ExecCommand")
+		return
+	}
+	if _, getErr := c.getPod(namespace, podName); getErr != nil {
+		err = utils.ReformatError("Issue in Stream: %v", getErr)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Executed = append(c.Executed, fmt.Sprintf("%s/%s: %s", namespace, podName, command))
+
+	for _, script := range c.scripts {
+		if !script.command.MatchString(command) {
+			continue
+		}
+		result := script.result
+		if result.Err != nil {
+			return 0, "", "", result.Err
+		}
+		if result.ExitCode != 0 {
+			err = utils.ReformatError("err: command terminated with non-zero exit code: exit status %d ; stderr: %s", result.ExitCode, result.Stderr)
+		}
+		return result.ExitCode, result.Stdout, result.Stderr, err
+	}
+	return
+}
+
+// GetPodIPs returns the IPs assigned when the pod was created
+func (c *Cluster) GetPodIPs(namespace, podName string) (podIP string, hostIP string, err error) {
+	pod, err := c.getPod(namespace, podName)
+	if err != nil {
+		return
+	}
+	return pod.Status.PodIP, pod.Status.HostIP, nil
+}
+
+// GetPodsByNamespace lists all pods in the namespace
+func (c *Cluster) GetPodsByNamespace(namespace string) (*apiv1.PodList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+}
+
+// GetAllPods lists the pods in every namespace
+func (c *Cluster) GetAllPods() (*apiv1.PodList, error) {
+	return c.GetPodsByNamespace(metav1.NamespaceAll)
+}
+
+// GetAllDeployments lists the deployments in every namespace
+func (c *Cluster) GetAllDeployments() (*appsv1.DeploymentList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// GetAllServices lists the services in every namespace
+func (c *Cluster) GetAllServices() (*apiv1.ServiceList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// GetNamespaces lists every namespace
+func (c *Cluster) GetNamespaces() (*apiv1.NamespaceList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+}
+
+// GetClusterRoleBindings lists every cluster role binding
+func (c *Cluster) GetClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+}
+
+// GetAllRoleBindings lists the role bindings in every namespace
+func (c *Cluster) GetAllRoleBindings() (*rbacv1.RoleBindingList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.RbacV1().RoleBindings(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// ServerVersion returns the version provided to SetServerVersion, or DefaultServerVersion
+func (c *Cluster) ServerVersion() (*version.Info, error) {
+	return c.Clientset.Discovery().ServerVersion()
+}
+
+// admit is a reactor for pod creation, which rejects pods matching an admission rule. Otherwise the pod
+// is mutated and given a running status, before being stored by the Clientset's default reactor.
+func (c *Cluster) admit(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+	pod, ok := action.(k8stesting.CreateAction).GetObject().(*apiv1.Pod)
+	if !ok {
+		return false, nil, nil
+	}
+	for _, rule := range c.admission {
+		if rule.Match(pod) {
+			return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "pods"}, pod.ObjectMeta.Name, fmt.Errorf("%s", rule.Reason))
+		}
+	}
+	for _, mutation := range c.mutations {
+		mutation(pod)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.podCount++
+	pod.Status.Phase = apiv1.PodRunning
+	pod.Status.HostIP = HostIP
+	pod.Status.PodIP = fmt.Sprintf("10.0.0.%d", c.podCount)
+	if pod.Spec.HostNetwork {
+		pod.Status.PodIP = HostIP
+	}
+	return false, nil, nil
+}
+
+func (c *Cluster) getPod(namespace, podName string) (*apiv1.Pod, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+}
+
+// add stores an object that already exists in the cluster. Objects are only added while setting up a test,
+// so an invalid object is a programming error.
+func (c *Cluster) add(object runtime.Object) {
+	err := c.Clientset.Tracker().Add(object.DeepCopyObject())
+	if err != nil {
+		panic(fmt.Sprintf("failed to add %T to the fake cluster: %v", object, err))
+	}
+}
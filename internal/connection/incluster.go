@@ -0,0 +1,86 @@
+package connection
+
+import (
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	sdkConfig "github.com/probr/probr-sdk/config"
+	"github.com/probr/probr-sdk/utils"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// serviceAccountDir is where Kubernetes mounts the credentials of a pod's service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+const inClusterName = "in-cluster"
+
+// InClusterMode reports whether the pack should authenticate using the service account of the pod it is running in.
+// When set to 'auto', in-cluster mode is used if the Kubernetes service env vars are present, unless a kubeconfig,
+// context or fleet of clusters has been configured.
+func InClusterMode() bool {
+	switch config.Vars.ServicePacks.Kubernetes.InCluster {
+	case "true":
+		return true
+	case "auto":
+		return os.Getenv("KUBERNETES_SERVICE_HOST") != "" && !kubeConfigProvided()
+	}
+	return false
+}
+
+// kubeConfigProvided reports whether the connection has been configured explicitly, or a kubeconfig file exists at
+// the default path, either of which takes precedence over the service account in 'auto' mode
+func kubeConfigProvided() bool {
+	k8s := config.Vars.ServicePacks.Kubernetes
+	if k8s.KubeConfigDir != "" || len(k8s.KubeContexts) > 0 || k8s.KubeContext != "" {
+		return true
+	}
+	if k8s.KubeConfigPath != config.DefaultKubeConfigPath() {
+		return true
+	}
+	_, err := os.Stat(k8s.KubeConfigPath)
+	return err == nil
+}
+
+// writeInClusterKubeConfig creates a kubeconfig file that points to the pod's service account credentials,
+// because the SDK connection requires a kubeconfig path for both the client set and command execution
+func writeInClusterKubeConfig() (path string, err error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		err = utils.ReformatError("In-cluster mode requires KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT to be set")
+		return
+	}
+	tokenFile := filepath.Join(serviceAccountDir, "token")
+	if _, statErr := os.Stat(tokenFile); statErr != nil {
+		err = utils.ReformatError("Service account token could not be read: %v", statErr)
+		return
+	}
+
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters[inClusterName] = &clientcmdapi.Cluster{
+		Server:               "https://" + net.JoinHostPort(host, port),
+		CertificateAuthority: filepath.Join(serviceAccountDir, "ca.crt"),
+	}
+	kubeConfig.AuthInfos[inClusterName] = &clientcmdapi.AuthInfo{
+		TokenFile: tokenFile,
+	}
+	kubeConfig.Contexts[inClusterName] = &clientcmdapi.Context{
+		Cluster:  inClusterName,
+		AuthInfo: inClusterName,
+	}
+	kubeConfig.CurrentContext = inClusterName
+
+	path = filepath.Join(sdkConfig.GlobalConfig.TmpDir, "in-cluster-kubeconfig")
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+	err = clientcmd.WriteToFile(*kubeConfig, path)
+	if err == nil {
+		log.Printf("[DEBUG] In-cluster kubeconfig written to %s", path)
+	}
+	return
+}
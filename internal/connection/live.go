@@ -0,0 +1,101 @@
+package connection
+
+import (
+	"context"
+	"time"
+
+	"github.com/probr/probr-sdk/providers/kubernetes/connection"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+const requestTimeout = 10 * time.Second
+
+// live extends the SDK connection with the cluster-wide queries that the SDK does not provide
+type live struct {
+	*connection.Conn
+	clientSet    kubernetes.Interface
+	clientSetErr error
+}
+
+func newLive(conn *connection.Conn) *live {
+	l := &live{Conn: conn}
+	restConfig, err := RestConfig()
+	if err == nil {
+		l.clientSet, err = kubernetes.NewForConfig(restConfig)
+	}
+	l.clientSetErr = err
+	return l
+}
+
+// GetAllPods lists the pods in every namespace
+func (l *live) GetAllPods() (*apiv1.PodList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// GetAllDeployments lists the deployments in every namespace
+func (l *live) GetAllDeployments() (*appsv1.DeploymentList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// GetAllServices lists the services in every namespace
+func (l *live) GetAllServices() (*apiv1.ServiceList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// GetNamespaces lists every namespace
+func (l *live) GetNamespaces() (*apiv1.NamespaceList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+}
+
+// GetClusterRoleBindings lists every cluster role binding
+func (l *live) GetClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+}
+
+// GetAllRoleBindings lists the role bindings in every namespace
+func (l *live) GetAllRoleBindings() (*rbacv1.RoleBindingList, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return l.clientSet.RbacV1().RoleBindings(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+}
+
+// ServerVersion returns the version reported by the API server
+func (l *live) ServerVersion() (*version.Info, error) {
+	if l.clientSetErr != nil {
+		return nil, l.clientSetErr
+	}
+	return l.clientSet.Discovery().ServerVersion()
+}
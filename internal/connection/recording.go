@@ -0,0 +1,429 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/probr/probr-sdk/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Connection modes supported by config.Vars.ServicePacks.Kubernetes.ConnectionMode
+const (
+	LiveMode   = "live"
+	RecordMode = "record"
+	ReplayMode = "replay"
+)
+
+// generatedName matches the timestamp and random suffix added to pod names by the SDK constructors,
+// so that recorded interactions can be matched against pods created during a later run
+var generatedName = regexp.MustCompile(`-\d{6}-\d{6}-\d{1,4}\b`)
+
+// randomLabel matches the random subdomains queried by the DNS exfiltration steps (see egress.RandomLabel)
+var randomLabel = regexp.MustCompile(`\bprobr-[a-z]{57}\b`)
+
+// Interaction is a single recorded call to the connection layer, including its response
+type Interaction struct {
+	Method string
+	Key    string // Normalized arguments used to match the interaction during replay
+
+	Pod                    *apiv1.Pod                     `json:",omitempty"`
+	PodList                *apiv1.PodList                 `json:",omitempty"`
+	DeploymentList         *appsv1.DeploymentList         `json:",omitempty"`
+	ServiceList            *apiv1.ServiceList             `json:",omitempty"`
+	NamespaceList          *apiv1.NamespaceList           `json:",omitempty"`
+	ClusterRoleBindingList *rbacv1.ClusterRoleBindingList `json:",omitempty"`
+	RoleBindingList        *rbacv1.RoleBindingList        `json:",omitempty"`
+	Version                *version.Info                  `json:",omitempty"`
+	Status                 int                            `json:",omitempty"`
+	Stdout                 string                         `json:",omitempty"`
+	Stderr                 string                         `json:",omitempty"`
+	PodIP                  string                         `json:",omitempty"`
+	HostIP                 string                         `json:",omitempty"`
+	Error                  *RecordedError                 `json:",omitempty"`
+}
+
+// RecordedError retains the details required to recreate an API status error, such as a 403 from an admission controller
+type RecordedError struct {
+	Message    string
+	StatusCode int32                 `json:",omitempty"`
+	Reason     metav1.StatusReason   `json:",omitempty"`
+	Details    *metav1.StatusDetails `json:",omitempty"`
+}
+
+// Recorder wraps a live connection and captures every interaction so that it can be saved as a fixture
+type Recorder struct {
+	conn         Connection
+	mutex        sync.Mutex
+	interactions []Interaction
+}
+
+// Replayer serves the interactions from a fixture in place of a live cluster.
+// Interactions with the same method and key are returned in the order they were recorded,
+// starting again from the first interaction each time the Replayer is rewound.
+type Replayer struct {
+	mutex        sync.Mutex
+	interactions map[string][]Interaction
+	used         map[string]int // Number of interactions served for each method and key since the last rewind
+}
+
+// NewRecorder returns a Connection that records every interaction made through conn
+func NewRecorder(conn Connection) *Recorder {
+	return &Recorder{conn: conn}
+}
+
+// Save writes all recorded interactions to the fixture file
+func (r *Recorder) Save(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return utils.ReformatError("Failed to encode recorded interactions: %v", err)
+	}
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return utils.ReformatError("Failed to write fixture file '%s': %v", path, err)
+	}
+	log.Printf("[INFO] Recorded %d cluster interactions to %s", len(r.interactions), path)
+	return nil
+}
+
+// Interactions returns a copy of the interactions recorded so far
+func (r *Recorder) Interactions() []Interaction {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+func (r *Recorder) record(interaction Interaction, err error) {
+	interaction.Error = recordError(err)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.interactions = append(r.interactions, interaction)
+}
+
+// ClusterIsDeployed records the result of the live call
+func (r *Recorder) ClusterIsDeployed() error {
+	err := r.conn.ClusterIsDeployed()
+	r.record(Interaction{Method: "ClusterIsDeployed"}, err)
+	return err
+}
+
+// CreatePodFromObject records the result of the live call
+func (r *Recorder) CreatePodFromObject(pod *apiv1.Pod, probeName string) (*apiv1.Pod, error) {
+	created, err := r.conn.CreatePodFromObject(pod, probeName)
+	r.record(Interaction{Method: "CreatePodFromObject", Key: podKey(pod, probeName), Pod: created}, err)
+	return created, err
+}
+
+// DeletePodIfExists records the result of the live call
+func (r *Recorder) DeletePodIfExists(podName, namespace, probeName string) error {
+	err := r.conn.DeletePodIfExists(podName, namespace, probeName)
+	r.record(Interaction{Method: "DeletePodIfExists", Key: key(namespace, podName, probeName)}, err)
+	return err
+}
+
+// ExecCommand records the result of the live call
+func (r *Recorder) ExecCommand(command, namespace, podName string) (status int, stdout string, stderr string, err error) {
+	status, stdout, stderr, err = r.conn.ExecCommand(command, namespace, podName)
+	r.record(Interaction{Method: "ExecCommand", Key: key(namespace, podName, command), Status: status, Stdout: stdout, Stderr: stderr}, err)
+	return
+}
+
+// GetPodIPs records the result of the live call
+func (r *Recorder) GetPodIPs(namespace, podName string) (podIP string, hostIP string, err error) {
+	podIP, hostIP, err = r.conn.GetPodIPs(namespace, podName)
+	r.record(Interaction{Method: "GetPodIPs", Key: key(namespace, podName), PodIP: podIP, HostIP: hostIP}, err)
+	return
+}
+
+// GetPodsByNamespace records the result of the live call
+func (r *Recorder) GetPodsByNamespace(namespace string) (*apiv1.PodList, error) {
+	pods, err := r.conn.GetPodsByNamespace(namespace)
+	r.record(Interaction{Method: "GetPodsByNamespace", Key: key(namespace), PodList: pods}, err)
+	return pods, err
+}
+
+// GetAllPods records the result of the live call
+func (r *Recorder) GetAllPods() (*apiv1.PodList, error) {
+	pods, err := r.conn.GetAllPods()
+	r.record(Interaction{Method: "GetAllPods", PodList: pods}, err)
+	return pods, err
+}
+
+// GetAllDeployments records the result of the live call
+func (r *Recorder) GetAllDeployments() (*appsv1.DeploymentList, error) {
+	deployments, err := r.conn.GetAllDeployments()
+	r.record(Interaction{Method: "GetAllDeployments", DeploymentList: deployments}, err)
+	return deployments, err
+}
+
+// GetAllServices records the result of the live call
+func (r *Recorder) GetAllServices() (*apiv1.ServiceList, error) {
+	services, err := r.conn.GetAllServices()
+	r.record(Interaction{Method: "GetAllServices", ServiceList: services}, err)
+	return services, err
+}
+
+// GetNamespaces records the result of the live call
+func (r *Recorder) GetNamespaces() (*apiv1.NamespaceList, error) {
+	namespaces, err := r.conn.GetNamespaces()
+	r.record(Interaction{Method: "GetNamespaces", NamespaceList: namespaces}, err)
+	return namespaces, err
+}
+
+// GetClusterRoleBindings records the result of the live call
+func (r *Recorder) GetClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error) {
+	bindings, err := r.conn.GetClusterRoleBindings()
+	r.record(Interaction{Method: "GetClusterRoleBindings", ClusterRoleBindingList: bindings}, err)
+	return bindings, err
+}
+
+// GetAllRoleBindings records the result of the live call
+func (r *Recorder) GetAllRoleBindings() (*rbacv1.RoleBindingList, error) {
+	bindings, err := r.conn.GetAllRoleBindings()
+	r.record(Interaction{Method: "GetAllRoleBindings", RoleBindingList: bindings}, err)
+	return bindings, err
+}
+
+// ServerVersion records the result of the live call
+func (r *Recorder) ServerVersion() (*version.Info, error) {
+	info, err := r.conn.ServerVersion()
+	r.record(Interaction{Method: "ServerVersion", Version: info}, err)
+	return info, err
+}
+
+// NewReplayer loads the interactions recorded in the fixture file
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to read fixture file '%s': %v", path, err)
+	}
+	var interactions []Interaction
+	err = json.Unmarshal(data, &interactions)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to decode fixture file '%s': %v", path, err)
+	}
+	replayer := &Replayer{interactions: make(map[string][]Interaction), used: make(map[string]int)}
+	for _, interaction := range interactions {
+		id := interaction.Method + " " + interaction.Key
+		replayer.interactions[id] = append(replayer.interactions[id], interaction)
+	}
+	log.Printf("[INFO] Replaying %d cluster interactions from %s", len(interactions), path)
+	return replayer, nil
+}
+
+// Rewind makes every recorded interaction available again, so that the fixture can be replayed by the next run
+func (r *Replayer) Rewind() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.used = make(map[string]int)
+}
+
+// next returns the earliest unused interaction matching the method and key
+func (r *Replayer) next(method, key string) (Interaction, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	id := method + " " + key
+	queue := r.interactions[id]
+	if r.used[id] >= len(queue) {
+		return Interaction{}, utils.ReformatError("No recorded interaction remaining for %s(%s)", method, key)
+	}
+	r.used[id]++
+	return queue[r.used[id]-1], nil
+}
+
+// ClusterIsDeployed returns the recorded result
+func (r *Replayer) ClusterIsDeployed() error {
+	interaction, err := r.next("ClusterIsDeployed", "")
+	if err != nil {
+		return err
+	}
+	return interaction.Error.toError()
+}
+
+// CreatePodFromObject returns the recorded pod, renamed to match the pod requested during this run
+func (r *Replayer) CreatePodFromObject(pod *apiv1.Pod, probeName string) (*apiv1.Pod, error) {
+	interaction, err := r.next("CreatePodFromObject", podKey(pod, probeName))
+	if err != nil {
+		return nil, err
+	}
+	created := interaction.Pod.DeepCopy() // The recorded pod is served again after a rewind, so must not be modified
+	if created != nil && pod != nil {
+		created.ObjectMeta.Name = pod.ObjectMeta.Name
+	}
+	return created, interaction.Error.toError()
+}
+
+// DeletePodIfExists returns the recorded result
+func (r *Replayer) DeletePodIfExists(podName, namespace, probeName string) error {
+	interaction, err := r.next("DeletePodIfExists", key(namespace, podName, probeName))
+	if err != nil {
+		return err
+	}
+	return interaction.Error.toError()
+}
+
+// ExecCommand returns the recorded exit code and output
+func (r *Replayer) ExecCommand(command, namespace, podName string) (status int, stdout string, stderr string, err error) {
+	interaction, err := r.next("ExecCommand", key(namespace, podName, command))
+	if err != nil {
+		return
+	}
+	return interaction.Status, interaction.Stdout, interaction.Stderr, interaction.Error.toError()
+}
+
+// GetPodIPs returns the recorded IPs
+func (r *Replayer) GetPodIPs(namespace, podName string) (podIP string, hostIP string, err error) {
+	interaction, err := r.next("GetPodIPs", key(namespace, podName))
+	if err != nil {
+		return
+	}
+	return interaction.PodIP, interaction.HostIP, interaction.Error.toError()
+}
+
+// GetPodsByNamespace returns the recorded pod list
+func (r *Replayer) GetPodsByNamespace(namespace string) (*apiv1.PodList, error) {
+	interaction, err := r.next("GetPodsByNamespace", key(namespace))
+	if err != nil {
+		return nil, err
+	}
+	return interaction.PodList, interaction.Error.toError()
+}
+
+// GetAllPods returns the recorded pod list
+func (r *Replayer) GetAllPods() (*apiv1.PodList, error) {
+	interaction, err := r.next("GetAllPods", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.PodList, interaction.Error.toError()
+}
+
+// GetAllDeployments returns the recorded deployment list
+func (r *Replayer) GetAllDeployments() (*appsv1.DeploymentList, error) {
+	interaction, err := r.next("GetAllDeployments", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.DeploymentList, interaction.Error.toError()
+}
+
+// GetAllServices returns the recorded service list
+func (r *Replayer) GetAllServices() (*apiv1.ServiceList, error) {
+	interaction, err := r.next("GetAllServices", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.ServiceList, interaction.Error.toError()
+}
+
+// GetNamespaces returns the recorded namespace list
+func (r *Replayer) GetNamespaces() (*apiv1.NamespaceList, error) {
+	interaction, err := r.next("GetNamespaces", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.NamespaceList, interaction.Error.toError()
+}
+
+// GetClusterRoleBindings returns the recorded cluster role binding list
+func (r *Replayer) GetClusterRoleBindings() (*rbacv1.ClusterRoleBindingList, error) {
+	interaction, err := r.next("GetClusterRoleBindings", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.ClusterRoleBindingList, interaction.Error.toError()
+}
+
+// GetAllRoleBindings returns the recorded role binding list
+func (r *Replayer) GetAllRoleBindings() (*rbacv1.RoleBindingList, error) {
+	interaction, err := r.next("GetAllRoleBindings", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.RoleBindingList, interaction.Error.toError()
+}
+
+// ServerVersion returns the recorded server version
+func (r *Replayer) ServerVersion() (*version.Info, error) {
+	interaction, err := r.next("ServerVersion", "")
+	if err != nil {
+		return nil, err
+	}
+	return interaction.Version, interaction.Error.toError()
+}
+
+// SaveRecording writes the interactions captured during the run, if the connection is in record mode
+func SaveRecording(path string) {
+	recorder, ok := State.(*Recorder)
+	if !ok {
+		return
+	}
+	err := recorder.Save(path)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
+// RewindReplay rewinds the fixture being replayed, if the connection is in replay mode
+func RewindReplay() {
+	if replayer, ok := State.(*Replayer); ok {
+		replayer.Rewind()
+	}
+}
+
+// podKey identifies a pod creation request by its normalized name and a digest of its spec,
+// so that different requests from the same probe are not confused with each other
+func podKey(pod *apiv1.Pod, probeName string) string {
+	if pod == nil {
+		return key(probeName)
+	}
+	spec, _ := json.Marshal(pod.Spec)
+	return key(pod.ObjectMeta.Namespace, pod.ObjectMeta.Name, probeName, string(spec))
+}
+
+func key(values ...string) string {
+	joined := generatedName.ReplaceAllString(strings.Join(values, "|"), "")
+	return randomLabel.ReplaceAllString(joined, "probr-random")
+}
+
+func recordError(err error) *RecordedError {
+	if err == nil {
+		return nil
+	}
+	recorded := &RecordedError{Message: err.Error()}
+	if statusErr, ok := err.(*apierrors.StatusError); ok {
+		recorded.StatusCode = statusErr.ErrStatus.Code
+		recorded.Reason = statusErr.ErrStatus.Reason
+		recorded.Details = statusErr.ErrStatus.Details
+	}
+	return recorded
+}
+
+// toError recreates the recorded error, retaining the status code of API errors
+func (e *RecordedError) toError() error {
+	if e == nil {
+		return nil
+	}
+	if e.StatusCode != 0 {
+		return &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    e.StatusCode,
+			Reason:  e.Reason,
+			Details: e.Details,
+			Message: e.Message,
+		}}
+	}
+	return fmt.Errorf("%s", e.Message)
+}
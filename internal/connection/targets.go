@@ -0,0 +1,132 @@
+package connection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	sdkConfig "github.com/probr/probr-sdk/config"
+	"github.com/probr/probr-sdk/utils"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// unsafeFileChars matches the characters of a context name, such as an EKS ARN, that are replaced in file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Target identifies a single cluster that the probes should be executed against
+type Target struct {
+	Name           string
+	KubeConfigPath string
+	KubeContext    string
+}
+
+// Targets lists the clusters described by config.Vars.ServicePacks.Kubernetes.
+// KubeConfigDir takes precedence over KubeContexts; if neither is set, a single target is returned.
+// Each file in KubeConfigDir is used with its own current-context, so that every target has a distinct context.
+func Targets() (targets []Target, err error) {
+	k8s := config.Vars.ServicePacks.Kubernetes
+	switch {
+	case k8s.KubeConfigDir != "":
+		files, readErr := ioutil.ReadDir(k8s.KubeConfigDir)
+		if readErr != nil {
+			return nil, utils.ReformatError("Failed to read kubeconfig directory '%s': %v", k8s.KubeConfigDir, readErr)
+		}
+		for _, file := range files {
+			if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(k8s.KubeConfigDir, file.Name())
+			kubeContext, contextErr := currentContext(path)
+			if contextErr != nil {
+				return nil, contextErr
+			}
+			targets = append(targets, Target{
+				Name:           strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())),
+				KubeConfigPath: path,
+				KubeContext:    kubeContext,
+			})
+		}
+		if len(targets) == 0 {
+			err = utils.ReformatError("No kubeconfig files found in '%s'", k8s.KubeConfigDir)
+		}
+	case len(k8s.KubeContexts) > 0:
+		for _, kubeContext := range k8s.KubeContexts {
+			targets = append(targets, Target{
+				Name:           kubeContext,
+				KubeConfigPath: k8s.KubeConfigPath,
+				KubeContext:    kubeContext,
+			})
+		}
+	default:
+		targets = append(targets, Target{
+			Name:           k8s.KubeContext,
+			KubeConfigPath: k8s.KubeConfigPath,
+			KubeContext:    k8s.KubeContext,
+		})
+	}
+	return
+}
+
+// Use points config.Vars at the provided target, ready for the next call to Connect
+func (t Target) Use() {
+	config.Vars.ServicePacks.Kubernetes.KubeConfigPath = t.KubeConfigPath
+	config.Vars.ServicePacks.Kubernetes.KubeContext = t.KubeContext
+}
+
+// currentContext reads the current-context of the kubeconfig file at the provided path
+func currentContext(path string) (string, error) {
+	kubeConfig, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return "", utils.ReformatError("Failed to read kubeconfig '%s': %v", path, err)
+	}
+	if kubeConfig.CurrentContext == "" {
+		return "", utils.ReformatError("Kubeconfig '%s' does not set a current-context", path)
+	}
+	return kubeConfig.CurrentContext, nil
+}
+
+// contextKubeConfig writes a kubeconfig containing only the named context, set as its current-context, and returns
+// its path. If no context is named, the provided path is returned unchanged.
+//
+// The SDK connection executes commands using the current-context of the kubeconfig file, and switches contexts by
+// rewriting the current-context of the user's default kubeconfig. Connecting with a kubeconfig per context instead
+// ensures that each target executes commands in its own cluster, and leaves the user's kubeconfig unmodified.
+func contextKubeConfig(kubeConfigPath, kubeContext string) (path string, err error) {
+	if kubeContext == "" {
+		return kubeConfigPath, nil
+	}
+	kubeConfig, err := contextConfig(kubeConfigPath, kubeContext)
+	if err != nil {
+		return "", err
+	}
+
+	path = filepath.Join(sdkConfig.GlobalConfig.TmpDir, "kubeconfigs", unsafeFileChars.ReplaceAllString(kubeContext, "_"))
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = clientcmd.WriteToFile(*kubeConfig, path)
+	}
+	if err != nil {
+		return "", utils.ReformatError("Failed to write kubeconfig for context '%s': %v", kubeContext, err)
+	}
+	return
+}
+
+// contextConfig reads the kubeconfig and reduces it to the named context, which is set as its current-context
+func contextConfig(kubeConfigPath, kubeContext string) (kubeConfig *clientcmdapi.Config, err error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeConfigPath
+	kubeConfig, err = rules.Load() // Relative certificate and key paths are resolved against the original file
+	if err != nil {
+		return nil, utils.ReformatError("Failed to read kubeconfig '%s': %v", kubeConfigPath, err)
+	}
+	kubeConfig.CurrentContext = kubeContext
+	err = clientcmdapi.MinifyConfig(kubeConfig)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to use context '%s' from kubeconfig '%s': %v", kubeContext, kubeConfigPath, err)
+	}
+	return
+}
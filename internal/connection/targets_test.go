@@ -0,0 +1,122 @@
+package connection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const fleetKubeConfig = `apiVersion: v1
+kind: Config
+current-context: prod-eu
+clusters:
+- name: prod-eu
+  cluster:
+    server: https://eu.example.com
+    certificate-authority: certs/eu.crt
+- name: prod-us
+  cluster:
+    server: https://us.example.com
+    certificate-authority: certs/us.crt
+users:
+- name: probr
+  user:
+    token: secret
+contexts:
+- name: prod-eu
+  context:
+    cluster: prod-eu
+    user: probr
+- name: arn:aws:eks:us-east-1:123456789012:cluster/prod-us
+  context:
+    cluster: prod-us
+    user: probr
+`
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-connection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestContextKubeConfig(t *testing.T) {
+	dir := tempDir(t)
+	kubeConfigPath := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(kubeConfigPath, []byte(fleetKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, err := contextKubeConfig(kubeConfigPath, ""); path != kubeConfigPath || err != nil {
+		t.Errorf("Expected the kubeconfig to be used unchanged without a context, found '%s' (%v)", path, err)
+	}
+
+	context := "arn:aws:eks:us-east-1:123456789012:cluster/prod-us"
+	kubeConfig, err := contextConfig(kubeConfigPath, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kubeConfig.CurrentContext != context || len(kubeConfig.Contexts) != 1 || len(kubeConfig.Clusters) != 1 {
+		t.Errorf("Expected a kubeconfig containing only context '%s', found current-context '%s' with %d contexts and %d clusters",
+			context, kubeConfig.CurrentContext, len(kubeConfig.Contexts), len(kubeConfig.Clusters))
+	}
+	if ca := kubeConfig.Clusters["prod-us"].CertificateAuthority; ca != filepath.Join(dir, "certs", "us.crt") {
+		t.Errorf("Expected the certificate authority to be resolved against the original kubeconfig, found '%s'", ca)
+	}
+
+	original, err := clientcmd.LoadFromFile(kubeConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.CurrentContext != "prod-eu" {
+		t.Errorf("Expected the original kubeconfig to be unmodified, found current-context '%s'", original.CurrentContext)
+	}
+
+	if _, err = contextConfig(kubeConfigPath, "staging"); err == nil {
+		t.Error("Expected an error for a context that is not in the kubeconfig")
+	}
+}
+
+func TestInClusterAutoMode(t *testing.T) {
+	home := tempDir(t)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	defer os.Unsetenv("KUBERNETES_SERVICE_HOST")
+	os.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+	k8s := &config.Vars.ServicePacks.Kubernetes
+	original := *k8s
+	defer func() { *k8s = original }()
+
+	var tests = []struct {
+		name     string
+		set      func()
+		expected bool
+	}{
+		{"no kubeconfig", func() {}, true},
+		{"explicit kubeconfig", func() { k8s.KubeConfigPath = filepath.Join(home, "probr.yaml") }, false},
+		{"explicit context", func() { k8s.KubeContext = "prod-eu" }, false},
+		{"fleet contexts", func() { k8s.KubeContexts = []string{"prod-eu"} }, false},
+		{"fleet directory", func() { k8s.KubeConfigDir = home }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			*k8s = original
+			k8s.InCluster = "auto"
+			k8s.KubeConfigPath = config.DefaultKubeConfigPath()
+			k8s.KubeContext = ""
+			k8s.KubeContexts = nil
+			k8s.KubeConfigDir = ""
+			test.set()
+			if inCluster := InClusterMode(); inCluster != test.expected {
+				t.Errorf("Expected in-cluster mode to be %v, found %v", test.expected, inCluster)
+			}
+		})
+	}
+}
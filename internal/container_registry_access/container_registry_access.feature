@@ -12,6 +12,8 @@ Feature: Protect image container registries
         Given a Kubernetes cluster exists which we can deploy into
 
     @k-cra-003
+    @cis-5.5.1
+    @severity-high
     Scenario: Ensure deployment from an unauthorised container registry is denied
         Then pod creation "succeeds" with container image from "authorized" registry
         And pod creation "is denied" with container image from "unauthorized" registry
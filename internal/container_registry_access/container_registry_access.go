@@ -3,61 +3,28 @@ package cra
 
 import (
 	"fmt"
-	"log"
-	"strings"
 
 	"github.com/cucumber/godog"
 	apiv1 "k8s.io/api/core/v1"
 
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
 	"github.com/probr/probr-pack-kubernetes/internal/config"
-	"github.com/probr/probr-pack-kubernetes/internal/connection"
-	"github.com/probr/probr-pack-kubernetes/internal/summary"
-	audit "github.com/probr/probr-sdk/audit"
-	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-pack-kubernetes/internal/features"
+	"github.com/probr/probr-pack-kubernetes/internal/steps"
 	"github.com/probr/probr-sdk/providers/kubernetes/constructors"
-	"github.com/probr/probr-sdk/providers/kubernetes/errors"
 	"github.com/probr/probr-sdk/utils"
 )
 
 type probeStruct struct{}
 
-// scenarioState holds the steps and state for any scenario in this probe
+// scenarioState provides the step definitions for this probe, using the state shared by all probes
 type scenarioState struct {
-	name        string
-	currentStep string
-	namespace   string
-	audit       *audit.Scenario
-	probe       *audit.Probe
-	pods        []string
+	*steps.ScenarioState
 }
 
 // Probe meets the service pack interface for adding the logic from this file
 var Probe probeStruct
-var scenario scenarioState
-
-func (scenario *scenarioState) aKubernetesClusterIsDeployed() error {
-	// Standard auditing logic to ensures panics are also audited
-	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
-
-	stepTrace.WriteString("Validate that a cluster can be reached using the specified kube config and context; ")
-
-	payload = struct {
-		KubeConfigPath string
-		KubeContext    string
-	}{
-		config.Vars.ServicePacks.Kubernetes.KubeConfigPath,
-		config.Vars.ServicePacks.Kubernetes.KubeContext,
-	}
-
-	err = connection.State.ClusterIsDeployed() // Must be assigned to 'err' be audited
-	return err
-}
+var scenario = scenarioState{&steps.Scenario}
 
 func (scenario *scenarioState) podCreationXWithContainerImageFromYRegistry(expectedResult, registryAccess string) error {
 	// Supported values for 'expectedResult':
@@ -70,12 +37,7 @@ func (scenario *scenarioState) podCreationXWithContainerImageFromYRegistry(expec
 
 	// Standard auditing logic to ensures panics are also audited
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
 	var shouldCreatePod bool
 	// Validate input values
@@ -102,15 +64,17 @@ func (scenario *scenarioState) podCreationXWithContainerImageFromYRegistry(expec
 	}
 
 	stepTrace.WriteString("Build a pod spec with default values; ")
-	podObject := constructors.PodSpec(Probe.Name(), scenario.namespace, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
+	podObject := constructors.PodSpec(Probe.Name(), scenario.Namespace, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
 
 	stepTrace.WriteString(fmt.Sprintf("Set container image registry to '%s' value in pod spec; ", registryAccess))
 	podObject.Spec.Containers[0].Image = imageFromConfig(isRegistryAuthorized)
 
 	stepTrace.WriteString("Create pod from spec; ")
-	createdPodObject, creationErr := scenario.createPodfromObject(podObject) // Pod name is saved to scenario state if successful
+	createdPodObject, creationErr := scenario.CreatePodFromObject(podObject) // Pod name is saved to scenario state if successful
 
 	stepTrace.WriteString(fmt.Sprintf("Validate pod creation %s; ", expectedResult))
+	var denialReason *admission.DenialReason
+	accepted := true
 	switch shouldCreatePod {
 	case true:
 		if creationErr != nil {
@@ -120,10 +84,7 @@ func (scenario *scenarioState) podCreationXWithContainerImageFromYRegistry(expec
 		if creationErr == nil {
 			err = utils.ReformatError("Pod creation succeeded, but should have been denied")
 		} else {
-			stepTrace.WriteString("Check that pod creation failed due to expected reason (403 Forbidden); ")
-			if !errors.IsStatusCode(403, creationErr) && !strings.Contains(creationErr.Error(), "ErrImagePull") {
-				err = utils.ReformatError("Unexpected error during Pod creation : %v", creationErr)
-			}
+			denialReason, accepted = scenario.ValidateDenial(creationErr, &stepTrace)
 		}
 	}
 
@@ -133,14 +94,22 @@ func (scenario *scenarioState) podCreationXWithContainerImageFromYRegistry(expec
 		RequestedPod   *apiv1.Pod
 		CreatedPod     *apiv1.Pod
 		CreationError  error
+		DenialReason   *admission.DenialReason
+		Mutations      []admission.Mutation
 	}{
 		ExpectedResult: expectedResult,
 		RegistryAccess: registryAccess,
 		RequestedPod:   podObject,
 		CreatedPod:     createdPodObject,
 		CreationError:  creationErr,
+		DenialReason:   denialReason,
+		Mutations:      admission.Mutations(podObject, createdPodObject),
 	}
 
+	if !accepted {
+		err = steps.Inconclusive("Pod creation was denied by %s, which is not one of the AdmissionControllers", denialReason)
+		return godog.ErrPending
+	}
 	return err
 }
 
@@ -151,7 +120,7 @@ func (probe probeStruct) Name() string {
 
 // Path presents the path of these feature files for external reference
 func (probe probeStruct) Path() string {
-	return probeengine.GetFeaturePath("internal", probe.Name())
+	return features.Path(probe.Name())
 }
 
 // ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
@@ -166,49 +135,14 @@ func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
 
 // ScenarioInitialize provides initialization logic before each scenario is executed
 func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
-
-	ctx.BeforeScenario(func(s *godog.Scenario) {
-		beforeScenario(&scenario, probe.Name(), s)
-	})
-
-	// Background
-	ctx.Step(`^a Kubernetes cluster exists which we can deploy into$`, scenario.aKubernetesClusterIsDeployed)
-
-	// Steps
-	ctx.Step(`^pod creation "([^"]*)" with container image from "([^"]*)" registry$`, scenario.podCreationXWithContainerImageFromYRegistry)
-
-	ctx.AfterScenario(func(s *godog.Scenario, err error) {
-		afterScenario(scenario, probe, s, err)
-	})
-
-	ctx.BeforeStep(func(st *godog.Step) {
-		scenario.currentStep = st.Text
-	})
-
-	ctx.AfterStep(func(st *godog.Step, err error) {
-		scenario.currentStep = ""
-	})
+	steps.Initialize(ctx, probe.Name())
 }
 
-func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
-	s.name = gs.Name
-	s.probe = summary.State.GetProbeLog(probeName)
-	s.audit = summary.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
-	s.pods = make([]string, 0)
-	s.namespace = config.Vars.ServicePacks.Kubernetes.ProbeNamespace
-	probeengine.LogScenarioStart(gs)
-}
-
-func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
-	if config.Vars.ServicePacks.Kubernetes.KeepPods == "false" {
-		for _, podName := range scenario.pods {
-			err = connection.State.DeletePodIfExists(podName, scenario.namespace, probe.Name())
-			if err != nil {
-				log.Printf("[ERROR] Could not retrieve pod from namespace '%s' for deletion: %s", scenario.namespace, err)
-			}
-		}
-	}
-	probeengine.LogScenarioEnd(gs)
+func init() {
+	steps.Register(func(ctx *godog.ScenarioContext) {
+		// Steps
+		ctx.Step(`^pod creation "([^"]*)" with container image from "([^"]*)" registry$`, scenario.podCreationXWithContainerImageFromYRegistry)
+	})
 }
 
 func imageFromConfig(authorized bool) string {
@@ -217,11 +151,3 @@ func imageFromConfig(authorized bool) string {
 	}
 	return config.Vars.ServicePacks.Kubernetes.UnauthorisedContainerImage
 }
-
-func (scenario *scenarioState) createPodfromObject(podObject *apiv1.Pod) (createdPodObject *apiv1.Pod, err error) {
-	createdPodObject, err = connection.State.CreatePodFromObject(podObject, Probe.Name())
-	if createdPodObject != nil && createdPodObject.ObjectMeta.Name != "" {
-		scenario.pods = append(scenario.pods, createdPodObject.ObjectMeta.Name)
-	}
-	return
-}
@@ -0,0 +1,74 @@
+package cra
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/connection/fake"
+	"github.com/probr/probr-pack-kubernetes/internal/probetest"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// unauthorisedImage matches pods using the UnauthorisedContainerImage
+func unauthorisedImage(pod *apiv1.Pod) bool {
+	return strings.HasPrefix(pod.Spec.Containers[0].Image, config.Vars.ServicePacks.Kubernetes.UnauthorisedContainerImage)
+}
+
+const gatekeeperDenial = `admission webhook "validation.gatekeeper.sh" denied the request: [allowed-repos] container uses an image from a disallowed registry`
+
+func TestScenarios(t *testing.T) {
+	var tests = []struct {
+		name     string
+		setup    func(cluster *fake.Cluster)
+		expected map[string][]string
+	}{
+		{
+			name:     "unauthorised registry is admitted",
+			expected: map[string][]string{"@k-cra-003": {"Failed"}},
+		},
+		{
+			name:     "unauthorised registry is denied",
+			setup:    func(cluster *fake.Cluster) { cluster.Deny(gatekeeperDenial, unauthorisedImage) },
+			expected: map[string][]string{"@k-cra-003": {"Passed"}},
+		},
+		{
+			name: "unauthorised registry is denied by quota",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`pods "probe" is forbidden: exceeded quota: pod-count, requested: pods=1, used: pods=10, limited: pods=10`, unauthorisedImage)
+			},
+			expected: map[string][]string{"@k-cra-003": {"Inconclusive"}},
+		},
+		{
+			name:     "authorised registry is denied",
+			setup:    func(cluster *fake.Cluster) { cluster.Deny(gatekeeperDenial, func(*apiv1.Pod) bool { return true }) },
+			expected: map[string][]string{"@k-cra-003": {"Failed"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := fake.NewCluster()
+			if test.setup != nil {
+				test.setup(cluster)
+			}
+			audit := probetest.RunAll(t, Probe, cluster)
+			probetest.ExpectResults(t, audit, test.expected)
+		})
+	}
+}
+
+// TestReplayIsRepeatable replays a fixture recorded against a fake cluster with a Gatekeeper deny,
+// as the daemon does when it runs the probes repeatedly in replay mode
+func TestReplayIsRepeatable(t *testing.T) {
+	replayer, err := connection.NewReplayer("testdata/k-cra-003.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for run := 1; run <= 2; run++ {
+		connection.State = replayer
+		connection.RewindReplay()
+		audit := probetest.Run(t, Probe, replayer, "@k-cra-003")
+		probetest.Expect(t, audit, "@k-cra-003", "Passed")
+	}
+}
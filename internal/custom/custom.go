@@ -0,0 +1,39 @@
+// Package custom runs user-written feature files from the directory set by CustomFeaturesDir, using the shared step library
+package custom
+
+import (
+	"github.com/cucumber/godog"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/steps"
+)
+
+type probeStruct struct{}
+
+// Probe meets the service pack interface for adding the logic from this file
+var Probe probeStruct
+
+// Name presents the name of this probe for external reference
+func (probe probeStruct) Name() string {
+	return "custom"
+}
+
+// Path presents the path of the custom feature files. Every feature file in the directory is executed.
+func (probe probeStruct) Path() string {
+	return config.Vars.ServicePacks.Kubernetes.CustomFeaturesDir
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+	ctx.BeforeSuite(func() {
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize provides every step in the shared library to the custom scenarios
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+	steps.Initialize(ctx, probe.Name())
+}
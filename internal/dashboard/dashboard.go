@@ -0,0 +1,246 @@
+// Package dashboard finds installations of the Kubernetes Web UI (Dashboard) in any namespace,
+// using the labels and images of the official manifests and Helm chart rather than resource names alone
+package dashboard
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-sdk/utils"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Labels are applied to the dashboard by the official manifests (k8s-app) and the Helm chart (app.kubernetes.io/name)
+var Labels = []string{
+	"k8s-app=kubernetes-dashboard",
+	"app.kubernetes.io/name=kubernetes-dashboard",
+}
+
+// Images are matched against every container image of deployments and pods
+var Images = []string{
+	"kubernetesui/dashboard",
+	"kubernetes-dashboard-amd64", // Images published before version 2
+}
+
+// PrivilegedRoles are the cluster roles that must not be bound to the dashboard's service account
+var PrivilegedRoles = []string{"cluster-admin", "admin", "edit"}
+
+// Resource is a deployment, service or pod identified as part of a dashboard installation
+type Resource struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+// Binding grants one of the PrivilegedRoles to a dashboard service account
+type Binding struct {
+	Kind           string
+	Namespace      string `json:",omitempty"`
+	Name           string
+	Role           string
+	ServiceAccount string
+}
+
+// Installation describes every dashboard resource found in the cluster
+type Installation struct {
+	Resources       []Resource
+	Endpoints       []string // URLs for each port of the dashboard's services
+	ServiceAccounts []string // Service accounts used by the dashboard, in the form namespace/name
+}
+
+// Found returns true if any dashboard resources were found
+func (i Installation) Found() bool {
+	return len(i.Resources) > 0
+}
+
+// Find searches all namespaces for deployments, services and pods belonging to a dashboard.
+// Pods in the SystemNamespace whose names start with the DashboardPodNamePrefix are also included.
+func Find() (installation Installation, err error) {
+	deployments, err := connection.State.GetAllDeployments()
+	if err != nil {
+		return installation, utils.ReformatError("Failed to list deployments: %v", err)
+	}
+	for _, deployment := range deployments.Items {
+		reason := match(deployment.ObjectMeta.Labels, deployment.Spec.Template.Spec)
+		if reason == "" {
+			reason = matchLabels(deployment.Spec.Template.ObjectMeta.Labels)
+		}
+		if reason != "" {
+			installation.add(Resource{"Deployment", deployment.Namespace, deployment.Name, reason})
+			installation.addServiceAccount(deployment.Namespace, deployment.Spec.Template.Spec.ServiceAccountName)
+		}
+	}
+
+	pods, err := connection.State.GetAllPods()
+	if err != nil {
+		return installation, utils.ReformatError("Failed to list pods: %v", err)
+	}
+	systemNamespace := config.Vars.ServicePacks.Kubernetes.SystemNamespace
+	prefix := config.Vars.ServicePacks.Kubernetes.DashboardPodNamePrefix
+	for _, pod := range pods.Items {
+		reason := match(pod.ObjectMeta.Labels, pod.Spec)
+		if reason == "" && prefix != "" && pod.Namespace == systemNamespace && strings.HasPrefix(pod.Name, prefix) {
+			reason = fmt.Sprintf("name starts with '%s'", prefix)
+		}
+		if reason != "" {
+			installation.add(Resource{"Pod", pod.Namespace, pod.Name, reason})
+			installation.addServiceAccount(pod.Namespace, pod.Spec.ServiceAccountName)
+		}
+	}
+
+	services, err := connection.State.GetAllServices()
+	if err != nil {
+		return installation, utils.ReformatError("Failed to list services: %v", err)
+	}
+	for _, service := range services.Items {
+		reason := matchLabels(service.ObjectMeta.Labels)
+		if reason == "" {
+			if reason = matchLabels(service.Spec.Selector); reason != "" {
+				reason = "selector " + reason
+			}
+		}
+		if reason != "" {
+			installation.add(Resource{"Service", service.Namespace, service.Name, reason})
+			installation.Endpoints = append(installation.Endpoints, endpoints(service)...)
+		}
+	}
+	return installation, nil
+}
+
+// PrivilegedBindings returns the cluster role bindings and role bindings that grant one of the
+// PrivilegedRoles to any of the service accounts, including through the service account groups
+func PrivilegedBindings(serviceAccounts []string) (bindings []Binding, err error) {
+	if len(serviceAccounts) == 0 {
+		return
+	}
+	clusterRoleBindings, err := connection.State.GetClusterRoleBindings()
+	if err != nil {
+		return nil, utils.ReformatError("Failed to list cluster role bindings: %v", err)
+	}
+	for _, binding := range clusterRoleBindings.Items {
+		if !privileged(binding.RoleRef) {
+			continue
+		}
+		for _, account := range serviceAccounts {
+			if boundTo(binding.Subjects, "", account) {
+				bindings = append(bindings, Binding{"ClusterRoleBinding", "", binding.Name, binding.RoleRef.Name, account})
+			}
+		}
+	}
+
+	roleBindings, err := connection.State.GetAllRoleBindings()
+	if err != nil {
+		return nil, utils.ReformatError("Failed to list role bindings: %v", err)
+	}
+	for _, binding := range roleBindings.Items {
+		if !privileged(binding.RoleRef) {
+			continue
+		}
+		for _, account := range serviceAccounts {
+			if boundTo(binding.Subjects, binding.Namespace, account) {
+				bindings = append(bindings, Binding{"RoleBinding", binding.Namespace, binding.Name, binding.RoleRef.Name, account})
+			}
+		}
+	}
+	return
+}
+
+func (i *Installation) add(resource Resource) {
+	i.Resources = append(i.Resources, resource)
+}
+
+func (i *Installation) addServiceAccount(namespace, name string) {
+	if name == "" {
+		name = "default"
+	}
+	account := namespace + "/" + name
+	for _, existing := range i.ServiceAccounts {
+		if existing == account {
+			return
+		}
+	}
+	i.ServiceAccounts = append(i.ServiceAccounts, account)
+}
+
+// match returns the reason that the labels or pod spec belong to a dashboard, or an empty string
+func match(labels map[string]string, spec apiv1.PodSpec) string {
+	if reason := matchLabels(labels); reason != "" {
+		return reason
+	}
+	containers := append([]apiv1.Container{}, spec.InitContainers...)
+	for _, container := range append(containers, spec.Containers...) {
+		for _, image := range Images {
+			if strings.Contains(container.Image, image) {
+				return fmt.Sprintf("image '%s'", container.Image)
+			}
+		}
+	}
+	return ""
+}
+
+func matchLabels(labels map[string]string) string {
+	for _, label := range Labels {
+		pair := strings.SplitN(label, "=", 2)
+		if value, found := labels[pair[0]]; found && value == pair[1] {
+			return "label " + label
+		}
+	}
+	return ""
+}
+
+// endpoints returns a URL for each port of the service, using HTTPS for the ports the dashboard serves TLS on
+func endpoints(service apiv1.Service) (urls []string) {
+	host := service.Spec.ClusterIP
+	if host == "" || host == apiv1.ClusterIPNone {
+		host = fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)
+	}
+	for _, port := range service.Spec.Ports {
+		scheme := "http"
+		if port.Port == 443 || port.Port == 8443 || strings.Contains(port.Name, "https") {
+			scheme = "https"
+		}
+		urls = append(urls, fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(host, strconv.Itoa(int(port.Port)))))
+	}
+	return
+}
+
+func privileged(role rbacv1.RoleRef) bool {
+	if role.Kind != "ClusterRole" {
+		return false
+	}
+	for _, name := range PrivilegedRoles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// boundTo returns true if the subjects include the service account, either directly or through its groups.
+// Subjects of a role binding without a namespace default to the binding's namespace.
+func boundTo(subjects []rbacv1.Subject, bindingNamespace, account string) bool {
+	namespace := strings.SplitN(account, "/", 2)[0]
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.ServiceAccountKind:
+			subjectNamespace := subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = bindingNamespace
+			}
+			if subjectNamespace+"/"+subject.Name == account {
+				return true
+			}
+		case rbacv1.GroupKind:
+			if subject.Name == "system:serviceaccounts" || subject.Name == "system:serviceaccounts:"+namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,342 @@
+// Package diff compares the audit output of two probe runs and reports the scenarios that changed between them
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/probr/probr-sdk/utils"
+)
+
+// Report describes every change found between a previous and a current run
+type Report struct {
+	Previous    string
+	Current     string
+	Regressions []ScenarioChange // Failed in the current run, but not in the previous run, including added scenarios
+	Fixed       []ScenarioChange // Failed in the previous run, passed in the current run
+	Added       []ScenarioChange // Only found in the current run
+	Removed     []ScenarioChange // Only found in the previous run
+	StepChanges []StepChange     // Steps with a different result or payload in scenarios found in both runs
+}
+
+// ScenarioChange describes the result of a single scenario in both runs
+type ScenarioChange struct {
+	Probe          string
+	Scenario       string
+	Tags           []string
+	PreviousResult string `json:",omitempty"`
+	CurrentResult  string `json:",omitempty"`
+}
+
+// StepChange describes the differences found for a single step
+type StepChange struct {
+	Probe          string
+	Scenario       string
+	Step           string
+	PreviousResult string
+	CurrentResult  string
+	PayloadChanges []FieldChange
+}
+
+// FieldChange describes a payload value that differs between runs
+type FieldChange struct {
+	Path     string
+	Previous interface{}
+	Current  interface{}
+}
+
+// auditProbe mirrors the audit file written by the SDK for each probe
+type auditProbe struct {
+	Scenarios map[int]*auditScenario
+}
+
+type auditScenario struct {
+	Name   string
+	Result string
+	Tags   []string
+	Steps  map[int]*auditStep
+}
+
+type auditStep struct {
+	Name    string
+	Result  string
+	Payload interface{}
+}
+
+// Generated pod names (such as podsecurity-191020-093512-4217) change on every run
+var generatedName = regexp.MustCompile(`-\d{6}-\d{6}-\d{1,4}\b`)
+
+// Payload values that are expected to change on every run
+var volatileFields = []string{
+	"metadata.name",
+	"metadata.uid",
+	"metadata.resourceVersion",
+	"metadata.creationTimestamp",
+	"metadata.selfLink",
+	"metadata.managedFields",
+	"status",
+	"PodName",
+	"PodIP",
+	"HostIP",
+}
+
+// Compare reads the audit files from both output directories and reports the differences
+func Compare(previousDir, currentDir string) (report Report, err error) {
+	report.Previous = previousDir
+	report.Current = currentDir
+
+	previous, err := readAuditDir(previousDir)
+	if err != nil {
+		return
+	}
+	current, err := readAuditDir(currentDir)
+	if err != nil {
+		return
+	}
+
+	for key, prev := range previous {
+		curr, found := current[key]
+		if !found {
+			report.Removed = append(report.Removed, change(key, prev, nil))
+			continue
+		}
+		switch {
+		case prev.Result != "Failed" && curr.Result == "Failed":
+			report.Regressions = append(report.Regressions, change(key, prev, curr))
+		case prev.Result == "Failed" && passed(curr.Result):
+			report.Fixed = append(report.Fixed, change(key, prev, curr))
+		}
+		report.StepChanges = append(report.StepChanges, compareSteps(key, prev, curr)...)
+	}
+	for key, curr := range current {
+		if _, found := previous[key]; !found {
+			report.Added = append(report.Added, change(key, nil, curr))
+			if curr.Result == "Failed" {
+				report.Regressions = append(report.Regressions, change(key, nil, curr))
+			}
+		}
+	}
+	report.sort()
+	return
+}
+
+// HasRegressions reports whether any scenario failed that did not fail in the previous run
+func (r Report) HasRegressions() bool {
+	return len(r.Regressions) > 0
+}
+
+// Print writes a human readable version of the report
+func (r Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Comparing '%s' to '%s'", r.Previous, r.Current)
+	fmt.Fprintln(w)
+	printScenarios(w, "Regressions", r.Regressions)
+	printScenarios(w, "Fixed", r.Fixed)
+	printScenarios(w, "Added", r.Added)
+	printScenarios(w, "Removed", r.Removed)
+	fmt.Fprintf(w, "Step changes (%d):", len(r.StepChanges))
+	fmt.Fprintln(w)
+	for _, step := range r.StepChanges {
+		fmt.Fprintf(w, "  [%s] %s > %s: %s -> %s", step.Probe, step.Scenario, step.Step, step.PreviousResult, step.CurrentResult)
+		fmt.Fprintln(w)
+		for _, field := range step.PayloadChanges {
+			fmt.Fprintf(w, "      %s: %v -> %v", field.Path, field.Previous, field.Current)
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// Write stores the report as JSON in the provided file path
+func (r Report) Write(path string) error {
+	if !utils.WriteAllowed(path) {
+		return utils.ReformatError("Failed to write diff report to %s", path)
+	}
+	return ioutil.WriteFile(path, utils.JSON(r), 0644)
+}
+
+func printScenarios(w io.Writer, title string, changes []ScenarioChange) {
+	fmt.Fprintf(w, "%s (%d):", title, len(changes))
+	fmt.Fprintln(w)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  [%s] %s %v", c.Probe, c.Scenario, c.Tags)
+		fmt.Fprintln(w)
+	}
+}
+
+// readAuditDir parses all probe audit files in the provided output directory (or its 'audit' subdirectory)
+// and returns the scenarios keyed by probe, scenario name and occurrence
+func readAuditDir(dir string) (scenarios map[string]*auditScenario, err error) {
+	auditDir := filepath.Join(dir, "audit")
+	if info, statErr := os.Stat(auditDir); statErr != nil || !info.IsDir() {
+		auditDir = dir
+	}
+	files, err := filepath.Glob(filepath.Join(auditDir, "*.json"))
+	if err != nil {
+		return
+	}
+	if len(files) == 0 {
+		err = utils.ReformatError("No audit files found in '%s'", auditDir)
+		return
+	}
+
+	scenarios = make(map[string]*auditScenario)
+	for _, file := range files {
+		data, readErr := ioutil.ReadFile(file)
+		if readErr != nil {
+			return nil, utils.ReformatError("Failed to read audit file '%s': %v", file, readErr)
+		}
+		var probe auditProbe
+		if jsonErr := json.Unmarshal(data, &probe); jsonErr != nil {
+			return nil, utils.ReformatError("Failed to parse audit file '%s': %v", file, jsonErr)
+		}
+		probeName := strings.TrimSuffix(filepath.Base(file), ".json")
+		occurrences := make(map[string]int)
+		for _, i := range sortedKeys(probe.Scenarios) {
+			scenario := probe.Scenarios[i]
+			occurrences[scenario.Name]++
+			key := fmt.Sprintf("%s/%s#%d", probeName, scenario.Name, occurrences[scenario.Name])
+			scenarios[key] = scenario
+		}
+	}
+	return
+}
+
+func compareSteps(key string, prev, curr *auditScenario) (changes []StepChange) {
+	probe, name := splitKey(key)
+	for i := 1; i <= len(prev.Steps) || i <= len(curr.Steps); i++ {
+		prevStep, currStep := prev.Steps[i], curr.Steps[i]
+		if prevStep == nil {
+			prevStep = &auditStep{Name: currStep.Name, Result: "Not Run"}
+		}
+		if currStep == nil {
+			currStep = &auditStep{Name: prevStep.Name, Result: "Not Run"}
+		}
+		fields := compareValues("", normalize(prevStep.Payload), normalize(currStep.Payload))
+		if prevStep.Result == currStep.Result && len(fields) == 0 {
+			continue
+		}
+		changes = append(changes, StepChange{
+			Probe:          probe,
+			Scenario:       name,
+			Step:           currStep.Name,
+			PreviousResult: prevStep.Result,
+			CurrentResult:  currStep.Result,
+			PayloadChanges: fields,
+		})
+	}
+	return
+}
+
+// compareValues walks two decoded JSON values and returns the leaf values that differ
+func compareValues(path string, prev, curr interface{}) (changes []FieldChange) {
+	if isVolatile(path) {
+		return
+	}
+	prevMap, prevIsMap := prev.(map[string]interface{})
+	currMap, currIsMap := curr.(map[string]interface{})
+	if prevIsMap && currIsMap {
+		keys := make(map[string]bool)
+		for k := range prevMap {
+			keys[k] = true
+		}
+		for k := range currMap {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			changes = append(changes, compareValues(joinPath(path, k), prevMap[k], currMap[k])...)
+		}
+		return
+	}
+	if !reflect.DeepEqual(prev, curr) {
+		changes = append(changes, FieldChange{Path: path, Previous: prev, Current: curr})
+	}
+	return
+}
+
+// normalize removes generated names from all string values in a decoded JSON value
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return generatedName.ReplaceAllString(v, "-<generated>")
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	}
+	return value
+}
+
+func isVolatile(path string) bool {
+	for _, field := range volatileFields {
+		if path == field || strings.HasSuffix(path, "."+field) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func passed(result string) bool {
+	return result == "Passed" || result == "Waived"
+}
+
+func change(key string, prev, curr *auditScenario) (c ScenarioChange) {
+	c.Probe, c.Scenario = splitKey(key)
+	if prev != nil {
+		c.Tags = prev.Tags
+		c.PreviousResult = prev.Result
+	}
+	if curr != nil {
+		c.Tags = curr.Tags
+		c.CurrentResult = curr.Result
+	}
+	return
+}
+
+func splitKey(key string) (probe, scenario string) {
+	parts := strings.SplitN(key, "/", 2)
+	probe = parts[0]
+	scenario = parts[1][:strings.LastIndex(parts[1], "#")]
+	return
+}
+
+func sortedKeys(scenarios map[int]*auditScenario) (keys []int) {
+	for k := range scenarios {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return
+}
+
+func (r *Report) sort() {
+	for _, list := range [][]ScenarioChange{r.Regressions, r.Fixed, r.Added, r.Removed} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Probe+list[i].Scenario < list[j].Probe+list[j].Scenario
+		})
+	}
+	sort.SliceStable(r.StepChanges, func(i, j int) bool {
+		return r.StepChanges[i].Probe+r.StepChanges[i].Scenario < r.StepChanges[j].Probe+r.StepChanges[j].Scenario
+	})
+}
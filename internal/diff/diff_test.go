@@ -0,0 +1,133 @@
+package diff
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeAudit writes an audit file for the probe in a new output directory, with one scenario per result.
+// An empty result leaves the scenario out of the file.
+func writeAudit(t *testing.T, probe string, results map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.MkdirAll(filepath.Join(dir, "audit"), 0755)
+
+	scenarios := make(map[int]*auditScenario)
+	for name, result := range results {
+		if result != "" {
+			scenarios[len(scenarios)+1] = &auditScenario{Name: name, Result: result, Tags: []string{"@" + name}}
+		}
+	}
+	data, _ := json.Marshal(auditProbe{Scenarios: scenarios})
+	if err = ioutil.WriteFile(filepath.Join(dir, "audit", probe+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCompareTransitions(t *testing.T) {
+	for _, test := range []struct {
+		previous, current string
+		regression        bool
+		fixed             bool
+		added             bool
+		removed           bool
+	}{
+		{previous: "Passed", current: "Failed", regression: true},
+		{previous: "Waived", current: "Failed", regression: true},
+		{previous: "Given Not Met", current: "Failed", regression: true},
+		{previous: "Inconclusive", current: "Failed", regression: true},
+		{previous: "", current: "Failed", regression: true, added: true},
+		{previous: "", current: "Passed", added: true},
+		{previous: "Passed", current: "", removed: true},
+		{previous: "Failed", current: "", removed: true},
+		{previous: "Failed", current: "Failed"},
+		{previous: "Failed", current: "Passed", fixed: true},
+		{previous: "Failed", current: "Waived", fixed: true},
+		{previous: "Failed", current: "Inconclusive"},
+		{previous: "Passed", current: "Given Not Met"},
+	} {
+		previous := writeAudit(t, "general", map[string]string{"k-gen-001": test.previous, "k-gen-002": "Passed"})
+		current := writeAudit(t, "general", map[string]string{"k-gen-001": test.current, "k-gen-002": "Passed"})
+		report, err := Compare(previous, current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, list := range []struct {
+			name     string
+			changes  []ScenarioChange
+			expected bool
+		}{
+			{"regression", report.Regressions, test.regression},
+			{"fixed", report.Fixed, test.fixed},
+			{"added", report.Added, test.added},
+			{"removed", report.Removed, test.removed},
+		} {
+			found := len(list.changes) == 1 && list.changes[0].Scenario == "k-gen-001"
+			if found != list.expected || len(list.changes) > 1 {
+				t.Errorf("'%s' -> '%s': expected %s to be %v, found %v", test.previous, test.current, list.name, list.expected, list.changes)
+			}
+		}
+		if report.HasRegressions() != test.regression {
+			t.Errorf("'%s' -> '%s': expected HasRegressions to be %v", test.previous, test.current, test.regression)
+		}
+	}
+}
+
+func TestCompareValuesIgnoresVolatileFields(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		previous string
+		current  string
+		changes  []string
+	}{
+		{
+			name:     "pod metadata and status",
+			previous: `{"CreatedPod": {"metadata": {"name": "a", "uid": "1", "resourceVersion": "10", "labels": {"app": "probr"}}, "status": {"phase": "Pending"}}}`,
+			current:  `{"CreatedPod": {"metadata": {"name": "b", "uid": "2", "resourceVersion": "20", "labels": {"app": "probr"}}, "status": {"phase": "Running"}}}`,
+		},
+		{
+			name:     "pod name and IPs",
+			previous: `{"PodName": "a", "PodIP": "10.0.0.1", "HostIP": "192.168.0.1", "Target": {"PodIP": "10.0.0.2"}}`,
+			current:  `{"PodName": "b", "PodIP": "10.0.0.3", "HostIP": "192.168.0.2", "Target": {"PodIP": "10.0.0.4"}}`,
+		},
+		{
+			name:     "generated names",
+			previous: `{"Command": "kubectl exec podsecurity-191020-093512-4217 -- ls"}`,
+			current:  `{"Command": "kubectl exec podsecurity-210101-120000-12 -- ls"}`,
+		},
+		{
+			name:     "stable values",
+			previous: `{"ExitCode": 0, "CreatedPod": {"metadata": {"labels": {"app": "probr"}}}, "Command": "ls probr-123"}`,
+			current:  `{"ExitCode": 1, "CreatedPod": {"metadata": {"labels": {"app": "other"}}}, "Command": "ls probr-456"}`,
+			changes:  []string{"Command", "CreatedPod.metadata.labels.app", "ExitCode"},
+		},
+	} {
+		var previous, current interface{}
+		json.Unmarshal([]byte(test.previous), &previous)
+		json.Unmarshal([]byte(test.current), &current)
+		changes := compareValues("", normalize(previous), normalize(current))
+
+		var paths []string
+		for _, change := range changes {
+			paths = append(paths, change.Path)
+		}
+		if len(paths) != len(test.changes) {
+			t.Errorf("%s: expected changes %v, found %v", test.name, test.changes, paths)
+			continue
+		}
+		for i := range paths {
+			if paths[i] != test.changes[i] {
+				t.Errorf("%s: expected changes %v, found %v", test.name, test.changes, paths)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package egress evaluates the results of egress attempts made from probe pods against the configured denial signatures
+package egress
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// httpCodeMarker separates the response body from the HTTP status code written by CurlCommand
+const httpCodeMarker = "probr-http-code:"
+
+// Attempt holds the outcome of an egress attempt made with CurlCommand
+type Attempt struct {
+	ExitCode   int
+	Body       string
+	HTTPStatus int
+	Stderr     string
+}
+
+// CurlCommand returns a curl command that writes the HTTP status code after the response body,
+// so that both can be compared to the denial signatures. The command contains no spaces within arguments.
+func CurlCommand(url string) string {
+	return "curl -s -S -m 10 -w \\n" + httpCodeMarker + "%{http_code} " + url // 10 second timeout should be enough
+}
+
+// ParseCurl separates the output of CurlCommand into the response body and HTTP status code
+func ParseCurl(exitCode int, stdout, stderr string) Attempt {
+	attempt := Attempt{ExitCode: exitCode, Body: stdout, Stderr: stderr}
+	if i := strings.LastIndex(stdout, httpCodeMarker); i >= 0 {
+		attempt.Body = strings.TrimSuffix(stdout[:i], "\n")
+		attempt.HTTPStatus, _ = strconv.Atoi(strings.TrimSpace(stdout[i+len(httpCodeMarker):]))
+	}
+	return attempt
+}
+
+// MatchDenial returns the name of the first configured denial signature that matches the attempt.
+// Every criterion provided in a signature must match; criteria that are not provided are ignored.
+func MatchDenial(attempt Attempt) (name string, matched bool, err error) {
+	for _, signature := range config.Vars.ServicePacks.Kubernetes.EgressDenialSignatures {
+		matched, err = matchSignature(signature.BodyPattern, signature.HTTPStatusCodes, signature.ExitCodes, signature.TLSErrorPattern, attempt)
+		if err != nil {
+			return signature.Name, false, utils.ReformatError("Invalid egress denial signature '%s': %v", signature.Name, err)
+		}
+		if matched {
+			return signature.Name, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func matchSignature(bodyPattern string, httpStatusCodes, exitCodes []int, tlsErrorPattern string, attempt Attempt) (bool, error) {
+	if bodyPattern == "" && len(httpStatusCodes) == 0 && len(exitCodes) == 0 && tlsErrorPattern == "" {
+		return false, nil // A signature without criteria would match every attempt
+	}
+	if bodyPattern != "" {
+		match, err := regexp.MatchString(bodyPattern, attempt.Body)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	if tlsErrorPattern != "" {
+		match, err := regexp.MatchString(tlsErrorPattern, attempt.Stderr)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	if len(httpStatusCodes) > 0 && !containsInt(httpStatusCodes, attempt.HTTPStatus) {
+		return false, nil
+	}
+	if len(exitCodes) > 0 && !containsInt(exitCodes, attempt.ExitCode) {
+		return false, nil
+	}
+	return true, nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
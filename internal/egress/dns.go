@@ -0,0 +1,95 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/probr/probr-sdk/utils"
+)
+
+// RandomLabelPrefix starts every label generated by RandomLabel, so that recorded runs can normalize them
+const RandomLabelPrefix = "probr-"
+
+var digStatus = regexp.MustCompile(`status: ([A-Z]+)`)
+
+// DNSResponse holds the parts of a dig response used to decide whether a query was answered
+type DNSResponse struct {
+	ExitCode  int
+	Status    string // The response code, such as NOERROR, NXDOMAIN, SERVFAIL or REFUSED. Empty if no reply was received.
+	Answers   []string
+	Authority []string
+}
+
+// DigCommand returns a dig command that queries the record type for the name.
+// The cluster's resolver is used unless a resolver is provided as host:port.
+func DigCommand(recordType, name, resolver string) (string, error) {
+	cmd := fmt.Sprintf("dig +tries=1 +time=%s", timeoutSeconds)
+	if resolver != "" {
+		host, port, err := net.SplitHostPort(resolver)
+		if err != nil {
+			return "", utils.ReformatError("Expected a resolver in the form host:port, but found '%s'", resolver)
+		}
+		cmd = fmt.Sprintf("%s -p %s @%s", cmd, port, host)
+	}
+	return fmt.Sprintf("%s %s %s", cmd, recordType, name), nil
+}
+
+// ParseDig reads the response code and the answer and authority records from the output of DigCommand
+func ParseDig(exitCode int, stdout string) DNSResponse {
+	response := DNSResponse{ExitCode: exitCode}
+	if match := digStatus.FindStringSubmatch(stdout); match != nil {
+		response.Status = match[1]
+	}
+	var section *[]string
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, ";; ANSWER SECTION"):
+			section = &response.Answers
+		case strings.HasPrefix(line, ";; AUTHORITY SECTION"):
+			section = &response.Authority
+		case line == "" || strings.HasPrefix(line, ";"):
+			section = nil
+		case section != nil:
+			*section = append(*section, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	return response
+}
+
+// Answered returns true if a resolver replied to the query, whether or not the name exists
+func (r DNSResponse) Answered() bool {
+	return r.ExitCode == 0 && r.Status != ""
+}
+
+// Resolved returns true if the response contains at least one answer record
+func (r DNSResponse) Resolved() bool {
+	return r.Answered() && r.Status == "NOERROR" && len(r.Answers) > 0
+}
+
+// AnsweredByZone returns true if the response was produced by the authoritative servers for the domain,
+// which shows that the query was forwarded beyond the cluster
+func (r DNSResponse) AnsweredByZone(domain string) bool {
+	if !r.Answered() || (r.Status != "NOERROR" && r.Status != "NXDOMAIN") {
+		return false
+	}
+	zone := strings.ToLower(strings.TrimSuffix(domain, ".") + ".")
+	for _, record := range append(r.Answers, r.Authority...) {
+		fields := strings.Fields(strings.ToLower(record))
+		if len(fields) < 4 {
+			continue
+		}
+		owner := fields[0]
+		if owner == zone || strings.HasSuffix(owner, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// RandomLabel returns a subdomain label of the maximum length, similar to those used to tunnel data over DNS
+func RandomLabel() string {
+	return RandomLabelPrefix + utils.RandomString(63-len(RandomLabelPrefix))
+}
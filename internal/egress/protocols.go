@@ -0,0 +1,66 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/probr/probr-sdk/utils"
+)
+
+// Protocols lists the protocols supported by ProtocolCommand, with the method used to detect a successful connection
+var Protocols = map[string]string{
+	"tcp":  "nc connects to the port",
+	"udp":  "a DNS query sent to the port receives a reply",
+	"icmp": "ping receives an echo reply",
+	"ssh":  "the server sends an SSH protocol banner",
+	"dns":  "a DNS-over-HTTPS query receives a JSON answer",
+}
+
+// timeoutSeconds is applied to every connection attempt
+const timeoutSeconds = "5"
+
+// ProtocolCommand returns the command used to attempt a connection to the target using the protocol.
+// The target is a host:port pair, except for icmp where the port is optional and ignored.
+func ProtocolCommand(protocol, target string) (string, error) {
+	if _, supported := Protocols[protocol]; !supported {
+		return "", utils.ReformatError("Unsupported protocol '%s'. Expected one of: tcp, udp, icmp, ssh, dns", protocol)
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		if protocol != "icmp" {
+			return "", utils.ReformatError("Expected a target in the form host:port, but found '%s'", target)
+		}
+		host = target
+	}
+
+	switch protocol {
+	case "tcp":
+		return fmt.Sprintf("nc -z -w %s %s %s", timeoutSeconds, host, port), nil
+	case "udp":
+		// UDP is connectionless, so a reply from an application is required to show the datagram was delivered
+		return fmt.Sprintf("dig +notcp +tries=1 +time=%s -p %s @%s example.com", timeoutSeconds, port, host), nil
+	case "icmp":
+		return fmt.Sprintf("ping -c 1 -W %s %s", timeoutSeconds, host), nil
+	case "ssh":
+		// No input is sent, so nc exits once the server's banner has been received and the connection is idle
+		return fmt.Sprintf("nc -w %s %s %s", timeoutSeconds, host, port), nil
+	}
+	return fmt.Sprintf("curl -s -S -m %s -H accept:application/dns-json https://%s/dns-query?name=example.com&type=A", timeoutSeconds, net.JoinHostPort(host, port)), nil
+}
+
+// Reached returns true if the result of ProtocolCommand shows that the target was reached
+func Reached(protocol string, exitCode int, stdout string) bool {
+	switch protocol {
+	case "ssh":
+		return strings.HasPrefix(strings.TrimSpace(stdout), "SSH-")
+	case "dns":
+		return exitCode == 0 && strings.Contains(stdout, `"Status"`)
+	}
+	return exitCode == 0
+}
+
+// ToolMissing returns true if the exit code shows that the command could not be found or executed in the container
+func ToolMissing(exitCode int) bool {
+	return exitCode == 126 || exitCode == 127
+}
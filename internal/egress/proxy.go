@@ -0,0 +1,31 @@
+package egress
+
+import (
+	"net/url"
+)
+
+// ProxyCurlCommand returns the same command as CurlCommand, with the request sent through the proxy
+func ProxyCurlCommand(address, proxy string) string {
+	return "curl -s -S -m 10 -x " + proxy + " -w \\n" + httpCodeMarker + "%{http_code} " + address
+}
+
+// Succeeded returns true if the attempt received a successful or redirect response that does not match a denial signature
+func Succeeded(attempt Attempt) (bool, error) {
+	if attempt.ExitCode != 0 || attempt.HTTPStatus < 200 || attempt.HTTPStatus >= 400 {
+		return false, nil
+	}
+	_, denied, err := MatchDenial(attempt)
+	return !denied, err
+}
+
+// RedactProxy hides any password in the proxy URL, so that it can be recorded in audit payloads
+func RedactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
@@ -0,0 +1,148 @@
+package features
+
+import (
+	"log"
+	"net/url"
+	"strings"
+)
+
+const (
+	// egressURLsTag marks the Examples tables whose URL column is populated from EgressTestURLs
+	egressURLsTag = "@egress-test-urls"
+
+	// egressProxyURLsTag marks the Examples tables whose URL column is populated from EgressProxyTestURLs
+	egressProxyURLsTag = "@egress-proxy-urls"
+)
+
+// expandURLs replaces the rows of each Examples table tagged with the tag by one row per URL.
+// A table whose rows all use the same scheme only receives the URLs with that scheme, so that the HTTP
+// and HTTPS scenarios can share a single list. Columns other than URL are copied from the first row.
+func expandURLs(content, tag string, urls []string) string {
+	lines := strings.Split(content, "\n")
+	var output []string
+	tagged := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		output = append(output, lines[i])
+		switch {
+		case strings.HasPrefix(line, "@"):
+			tagged = tagged || hasTag(line, tag)
+			continue
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case !tagged || !strings.HasPrefix(line, "Examples:"):
+			tagged = false
+			continue
+		}
+		tagged = false
+
+		// Collect the table following the Examples keyword
+		var table []string
+		j := i + 1
+		for ; j < len(lines); j++ {
+			row := strings.TrimSpace(lines[j])
+			if strings.HasPrefix(row, "|") {
+				table = append(table, lines[j])
+			} else if row != "" && !strings.HasPrefix(row, "#") {
+				break
+			} else if len(table) > 0 {
+				break
+			}
+		}
+		rows, ok := replaceRows(table, tag, urls)
+		if !ok {
+			continue
+		}
+		output = append(output, rows...)
+		i = j - 1
+	}
+	return strings.Join(output, "\n")
+}
+
+// replaceRows returns the table with its rows replaced, or false if the table has no URL column
+func replaceRows(table []string, tag string, urls []string) ([]string, bool) {
+	if len(table) == 0 {
+		return nil, false
+	}
+	indent := table[0][:len(table[0])-len(strings.TrimLeft(table[0], " \t"))]
+	header := cells(table[0])
+	column := -1
+	for k, name := range header {
+		if name == "URL" {
+			column = k
+		}
+	}
+	if column < 0 {
+		log.Printf("[WARN] Examples tagged %s have no URL column and were not changed", tag)
+		return nil, false
+	}
+
+	template := make([]string, len(header))
+	schemes := map[string]bool{}
+	for k, row := range table[1:] {
+		values := cells(row)
+		if k == 0 {
+			copy(template, values)
+		}
+		if column < len(values) {
+			if u, err := url.Parse(values[column]); err == nil {
+				schemes[u.Scheme] = true
+			}
+		}
+	}
+
+	rows := [][]string{header}
+	for _, address := range urls {
+		u, err := url.Parse(address)
+		if err != nil || (len(schemes) == 1 && !schemes[u.Scheme]) {
+			continue
+		}
+		row := append([]string{}, template...)
+		row[column] = address
+		rows = append(rows, row)
+	}
+	if len(rows) == 1 {
+		log.Printf("[WARN] None of the configured URLs apply to an Examples table tagged %s, so its scenarios will not run", tag)
+	}
+	return formatTable(indent, rows), true
+}
+
+func hasTag(line, tag string) bool {
+	for _, field := range strings.Fields(line) {
+		if field == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func cells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	values := strings.Split(row, "|")
+	for k := range values {
+		values[k] = strings.TrimSpace(values[k])
+	}
+	return values
+}
+
+// formatTable aligns the columns of the rows, in the same layout as the embedded feature files
+func formatTable(indent string, rows [][]string) []string {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for k, value := range row {
+			if len(value) > widths[k] {
+				widths[k] = len(value)
+			}
+		}
+	}
+	var lines []string
+	for _, row := range rows {
+		line := indent + "|"
+		for k, value := range row {
+			line += " " + value + strings.Repeat(" ", widths[k]-len(value)) + " |"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
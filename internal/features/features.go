@@ -0,0 +1,84 @@
+// Package features locates the feature files for each probe, combining the embedded features
+// with any overrides and additions found in the directory set by FeatureOverrideDir, and
+// expanding the Examples tables populated from configuration
+package features
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	sdkConfig "github.com/probr/probr-sdk/config"
+	"github.com/probr/probr-sdk/probeengine"
+)
+
+// Path returns the path to be executed for the named probe.
+//
+// Without a FeatureOverrideDir, EgressTestURLs or EgressProxyTestURLs, this is the embedded feature file. Otherwise a directory
+// is assembled containing <FeatureOverrideDir>/<probe>.feature in place of the embedded file (if present),
+// plus any additional feature files found in <FeatureOverrideDir>/<probe>/.
+func Path(probeName string) string {
+	embedded := probeengine.GetFeaturePath("internal", probeName)
+	overrideDir := config.Vars.ServicePacks.Kubernetes.FeatureOverrideDir
+	if overrideDir == "" && len(config.Vars.ServicePacks.Kubernetes.EgressTestURLs) == 0 &&
+		len(config.Vars.ServicePacks.Kubernetes.EgressProxyTestURLs) == 0 {
+		log.Printf("[DEBUG] Probe '%s' using embedded feature file", probeName)
+		return embedded
+	}
+
+	featureDir := filepath.Join(sdkConfig.GlobalConfig.TmpDir, "features", probeName)
+	err := os.RemoveAll(featureDir)
+	if err == nil {
+		err = os.MkdirAll(featureDir, 0755)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to prepare feature directory for probe '%s', using embedded feature file: %v", probeName, err)
+		return embedded
+	}
+
+	featureName := probeName + ".feature"
+	source := filepath.Join(overrideDir, featureName)
+	if _, err := os.Stat(source); overrideDir != "" && err == nil {
+		log.Printf("[INFO] Probe '%s' using feature file from override directory: %s", probeName, source)
+	} else {
+		log.Printf("[INFO] Probe '%s' using embedded feature file", probeName)
+		source = embedded
+	}
+	err = copyFile(source, filepath.Join(featureDir, featureName))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return embedded
+	}
+
+	var additional []string
+	if overrideDir != "" {
+		additional, _ = filepath.Glob(filepath.Join(overrideDir, probeName, "*.feature"))
+	}
+	for _, path := range additional {
+		// Prefixed to avoid overwriting the primary feature file if an additional file shares its name
+		err = copyFile(path, filepath.Join(featureDir, "additional-"+filepath.Base(path)))
+		if err != nil {
+			log.Printf("[ERROR] %v", err)
+			continue
+		}
+		log.Printf("[INFO] Probe '%s' using additional feature file: %s", probeName, path)
+	}
+	return featureDir
+}
+
+// copyFile copies the feature file, replacing the rows of any Examples populated from configuration
+func copyFile(source, destination string) error {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	if urls := config.Vars.ServicePacks.Kubernetes.EgressTestURLs; len(urls) > 0 {
+		data = []byte(expandURLs(string(data), egressURLsTag, urls))
+	}
+	if urls := config.Vars.ServicePacks.Kubernetes.EgressProxyTestURLs; len(urls) > 0 {
+		data = []byte(expandURLs(string(data), egressProxyURLsTag, urls))
+	}
+	return ioutil.WriteFile(destination, data, 0644)
+}
@@ -0,0 +1,118 @@
+// Package fleet rolls up the results of probe runs against multiple clusters
+package fleet
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// controlTag matches the scenario identifiers used in the feature files, such as @k-pod-001
+var controlTag = regexp.MustCompile(`^@k-[a-z]+-\d+$`)
+
+// Rollup holds the overall results for every cluster in the fleet
+type Rollup struct {
+	Clusters map[string]*ClusterResult
+	Controls map[string][]string // Failed control tag mapped to the names of the clusters it failed on
+}
+
+// ClusterResult holds the summary status of a single cluster
+type ClusterResult struct {
+	Status       string
+	ProbesPassed int
+	ProbesFailed int
+	Error        string `json:",omitempty"`
+	Output       string
+}
+
+// NewRollup creates an empty fleet roll-up
+func NewRollup() *Rollup {
+	return &Rollup{
+		Clusters: make(map[string]*ClusterResult),
+		Controls: make(map[string][]string),
+	}
+}
+
+// Add records the results of a single cluster run
+func (r *Rollup) Add(cluster, outputDir string, state *audit.SummaryState, runErr error) {
+	result := &ClusterResult{
+		Status:       state.Status,
+		ProbesPassed: state.ProbesPassed,
+		ProbesFailed: state.ProbesFailed,
+		Output:       outputDir,
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	r.Clusters[cluster] = result
+
+	for _, probe := range state.Probes {
+		for _, scenario := range probe.Scenarios {
+			if scenario.Result != "Failed" {
+				continue
+			}
+			for _, tag := range scenario.Tags {
+				if controlTag.MatchString(tag) && !contains(r.Controls[tag], cluster) {
+					r.Controls[tag] = append(r.Controls[tag], cluster)
+				}
+			}
+		}
+	}
+}
+
+// Failed reports whether any cluster run returned an error
+func (r *Rollup) Failed() bool {
+	for _, result := range r.Clusters {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a human readable overview of the failed controls per cluster
+func (r *Rollup) Print(w io.Writer) {
+	fmt.Fprintf(w, "Fleet summary (%d clusters):", len(r.Clusters))
+	fmt.Fprintln(w)
+	for _, name := range sortedKeys(r.Clusters) {
+		fmt.Fprintf(w, "  %s: %s", name, r.Clusters[name].Status)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "Failed controls:")
+	var controls []string
+	for control := range r.Controls {
+		controls = append(controls, control)
+	}
+	sort.Strings(controls)
+	for _, control := range controls {
+		sort.Strings(r.Controls[control])
+		fmt.Fprintf(w, "  %s: %v", control, r.Controls[control])
+		fmt.Fprintln(w)
+	}
+}
+
+// Write stores the roll-up as JSON in the provided file path
+func (r *Rollup) Write(path string) error {
+	if !utils.WriteAllowed(path) {
+		return utils.ReformatError("Failed to write fleet summary to %s", path)
+	}
+	return ioutil.WriteFile(path, utils.JSON(r), 0644)
+}
+
+func contains(list []string, value string) bool {
+	_, found := utils.FindString(list, value)
+	return found
+}
+
+func sortedKeys(clusters map[string]*ClusterResult) (keys []string) {
+	for k := range clusters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -9,19 +9,24 @@ Feature: General Cluster Security Configurations
         Given a Kubernetes cluster exists which we can deploy into
 
     @k-gen-001
+    @cis-6.10.1
+    @severity-medium
+    @cluster-scope
     Scenario: Ensure Kubernetes Web UI is disabled
         The Kubernetes Web UI (Dashboard) has been a historical source of vulnerability and should only be deployed when necessary.
 
         Then the Kubernetes Web UI is disabled
 
     @k-gen-002
-    Scenario Outline: Test outgoing connectivity of a deployed pod
+    @severity-high
+    Scenario Outline: Test outgoing connectivity of a deployed pod to <URL>
         Ensure that containers running inside Kubernetes clusters cannot directly access the Internet
         So that Internet traffic can be inspected and controlled
 
         When pod creation "succeeds" in the "probr" namespace
         Then the result of a process inside the pod establishing a direct connection to "<URL>" is blocked
 
+        @egress-test-urls
         Examples:
             | URL                           |
             | http://www.ubuntu.com         |
@@ -29,20 +34,131 @@ Feature: General Cluster Security Configurations
             | http://www.stackoverflow.com  |
 
     @k-gen-003
-    Scenario Outline: Test HTTPS outgoing connectivity of a deployed pod
+    @severity-high
+    Scenario Outline: Test HTTPS outgoing connectivity of a deployed pod to <URL>
         Ensure that containers running inside Kubernetes clusters cannot directly access the Internet
         So that Internet traffic can be inspected and controlled
 
         When pod creation "succeeds" in the "probr" namespace
         Then the result of a process inside the pod establishing a direct connection to "<URL>" is blocked
 
+        @egress-test-urls
         Examples:
             | URL                           |
             | https://www.ubuntu.com        |
             | https://www.google.com        |
             | https://www.stackoverflow.com |
 
-    @k-gen-003
+    @k-gen-004
+    @severity-high
+    Scenario Outline: Test non-HTTP outgoing connectivity of a deployed pod
+        Ensure that containers running inside Kubernetes clusters cannot directly reach the Internet over other protocols
+        So that data cannot be exfiltrated around the inspection of web traffic
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then the result of a "<Protocol>" connection to "<Target>" is blocked
+
+        Examples:
+            | Protocol | Target                 |
+            | tcp      | portquiz.net:21        |
+            | tcp      | portquiz.net:25        |
+            | tcp      | portquiz.net:445       |
+            | tcp      | portquiz.net:3389      |
+            | tcp      | portquiz.net:4444      |
+            | tcp      | portquiz.net:8080      |
+            | udp      | 8.8.8.8:53             |
+            | udp      | 1.1.1.1:53             |
+            | icmp     | 8.8.8.8                |
+            | ssh      | github.com:22          |
+            | dns      | cloudflare-dns.com:443 |
+            | dns      | 1.1.1.1:443            |
+
+    @k-gen-005
+    @severity-high
+    Scenario Outline: Test resolution of public names through cluster DNS
+        Ensure that containers running inside Kubernetes clusters cannot resolve arbitrary external names
+        So that DNS cannot be used to tunnel data out of the cluster
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then the pod cannot resolve the public name "<Name>" through cluster DNS
+
+        Examples:
+            | Name              |
+            | www.ubuntu.com    |
+            | www.google.com    |
+
+    @k-gen-006
+    @severity-medium
+    @requires-egress-proxy
+    Scenario Outline: Test outgoing connectivity through the egress proxy to <URL>
+        Ensure that approved destinations can only be reached through the corporate proxy
+        So that the network is neither over-permissive nor over-restrictive
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then a request to "<URL>" succeeds through the egress proxy and is blocked without it
+
+        @egress-proxy-urls
+        Examples:
+            | URL                    |
+            | https://www.ubuntu.com |
+            | https://www.google.com |
+
+    @k-gen-007
+    @cis-5.3.2
+    @severity-medium
+    Scenario: Ensure pods cannot reach other pods unless allowed by a network policy
+        Ensure that the probe namespace has a default deny network policy
+        So that a compromised pod cannot reach other workloads in the namespace
+
+        When pod "client" is created
+        And pod "server" is created
+        Then the connection from pod "client" to pod "server" on port "8080" is "prevented"
+
+    @k-gen-008
+    @severity-high
+    Scenario Outline: Test direct queries to external resolvers
+        Ensure that containers running inside Kubernetes clusters can only use the cluster's resolver
+        So that DNS traffic can be inspected and controlled
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then the pod cannot query the external resolver "<Resolver>" directly
+
+        Examples:
+            | Resolver   |
+            | 8.8.8.8:53 |
+            | 1.1.1.1:53 |
+
+    @k-gen-009
+    @severity-high
+    Scenario: Test forwarding of TXT lookups for random subdomains
+        Ensure that the cluster's resolver does not forward lookups for arbitrary subdomains to the Internet
+        So that data encoded in DNS queries cannot reach an attacker's name server
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then TXT lookups for random subdomains of "example.com" are not forwarded upstream
+
+    @k-gen-010
+    @cis-5.7.4
+    @severity-low
+    @cluster-scope
     Scenario: The default namespace should not be used
         When pod creation "succeeds" in the "probr" namespace
         Then pod creation "fails" in the "default" namespace
+
+    @k-gen-011
+    @cis-6.10.1
+    @severity-high
+    Scenario: Ensure the Kubernetes Web UI cannot be reached from a pod
+        A dashboard that is installed must not be reachable by workloads running in the cluster.
+
+        When pod creation "succeeds" in the "probr" namespace
+        Then the Kubernetes Web UI is not reachable from the pod
+
+    @k-gen-012
+    @cis-6.10.1
+    @severity-critical
+    @cluster-scope
+    Scenario: Ensure the Kubernetes Web UI does not have privileged access
+        A dashboard whose service account is bound to a privileged role grants that access to anyone who can reach it.
+
+        Then the Kubernetes Web UI service account is not bound to a privileged role
@@ -3,7 +3,6 @@ package general
 
 import (
 	"fmt"
-	"log"
 	"net/url"
 	"strings"
 
@@ -11,92 +10,175 @@ import (
 
 	"github.com/cucumber/godog"
 
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
 	"github.com/probr/probr-pack-kubernetes/internal/config"
 	"github.com/probr/probr-pack-kubernetes/internal/connection"
-	"github.com/probr/probr-pack-kubernetes/internal/summary"
-	audit "github.com/probr/probr-sdk/audit"
-	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-pack-kubernetes/internal/dashboard"
+	"github.com/probr/probr-pack-kubernetes/internal/egress"
+	"github.com/probr/probr-pack-kubernetes/internal/features"
+	"github.com/probr/probr-pack-kubernetes/internal/pods"
+	"github.com/probr/probr-pack-kubernetes/internal/steps"
+	"github.com/probr/probr-pack-kubernetes/internal/toolkit"
 	"github.com/probr/probr-sdk/providers/kubernetes/constructors"
 
 	"github.com/probr/probr-sdk/utils"
 )
 
+// EgressProxyTag marks scenarios that verify the EgressProxy, which are excluded from the run when no proxy is configured
+const EgressProxyTag = "@requires-egress-proxy"
+
 type probeStruct struct{}
 
+// scenarioState provides the step definitions for this probe, using the state shared by all probes
 type scenarioState struct {
-	name        string
-	currentStep string
-	namespace   string
-	audit       *audit.Scenario
-	probe       *audit.Probe
-	pods        map[string][]string // A Key/Value collection to store all pods created within scenario. Key is the namespace where pods are created.
+	*steps.ScenarioState
 }
 
 // Probe meets the service pack interface for adding the logic from this file
 var Probe probeStruct
-var scenario scenarioState
+var scenario = scenarioState{&steps.Scenario}
 
-func (scenario *scenarioState) aKubernetesClusterIsDeployed() error {
-	// Standard auditing logic to ensures panics are also audited
-	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+func (scenario *scenarioState) theKubernetesWebUIIsDisabled() error {
 
-	stepTrace.WriteString("Validate that a cluster can be reached using the specified kube config and context; ")
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
+	stepTrace.WriteString("Search all namespaces for deployments, services and pods with the dashboard's labels or images; ")
+	installation, err := dashboard.Find()
 	payload = struct {
-		KubeConfigPath string
-		KubeContext    string
+		Labels                 []string
+		Images                 []string
+		SystemNamespace        string
+		DashboardPodNamePrefix string
+		Resources              []dashboard.Resource
 	}{
-		config.Vars.ServicePacks.Kubernetes.KubeConfigPath,
-		config.Vars.ServicePacks.Kubernetes.KubeContext,
+		Labels:                 dashboard.Labels,
+		Images:                 dashboard.Images,
+		SystemNamespace:        config.Vars.ServicePacks.Kubernetes.SystemNamespace,
+		DashboardPodNamePrefix: config.Vars.ServicePacks.Kubernetes.DashboardPodNamePrefix,
+		Resources:              installation.Resources,
+	}
+	if err != nil {
+		return err
 	}
 
-	err = connection.State.ClusterIsDeployed() // Must be assigned to 'err' be audited
+	stepTrace.WriteString("Confirm that no dashboard resources were found; ")
+	if installation.Found() {
+		var found []string
+		for _, resource := range installation.Resources {
+			found = append(found, fmt.Sprintf("%s %s/%s (%s)", resource.Kind, resource.Namespace, resource.Name, resource.Reason))
+		}
+		err = utils.ReformatError("Dashboard UI resources were found: %s", strings.Join(found, ", "))
+	}
 	return err
 }
 
-func (scenario *scenarioState) theKubernetesWebUIIsDisabled() error {
+func (scenario *scenarioState) theKubernetesWebUIIsNotReachableFromThePod() error {
 
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
-	kubeSystemNamespace := config.Vars.ServicePacks.Kubernetes.SystemNamespace
-	dashboardPodNamePrefix := config.Vars.ServicePacks.Kubernetes.DashboardPodNamePrefix
-	stepTrace.WriteString(fmt.Sprintf("Attempt to find a pod in the '%s' namespace with the prefix '%s'; ", kubeSystemNamespace, dashboardPodNamePrefix))
+	// Guard clause - Ensure pod was created in previous step
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return err
+	}
 
-	stepTrace.WriteString(fmt.Sprintf("Get all pods from '%s' namespace; ", kubeSystemNamespace))
-	podList, getError := connection.State.GetPodsByNamespace(kubeSystemNamespace) // Also validates if provided namespace is valid
-	if getError != nil {
-		err = utils.ReformatError("An error occurred while retrieving pods from '%s' namespace. Error: %s", kubeSystemNamespace, getError)
+	stepTrace.WriteString("Find the dashboard's services in all namespaces; ")
+	installation, err := dashboard.Find()
+	if err != nil {
 		return err
 	}
 
-	stepTrace.WriteString(fmt.Sprintf("Confirm a pod with '%s' prefix doesn't exist; ", dashboardPodNamePrefix))
-	for _, pod := range podList.Items {
-		if strings.HasPrefix(pod.Name, dashboardPodNamePrefix) {
-			err = utils.ReformatError("Dashboard UI Pod was found: '%s'", pod.Name)
+	type request struct {
+		URL      string
+		Command  string
+		ExitCode int
+		StdOut   string
+		StdErr   string
+		Reached  bool
+	}
+	var requests []request
+	var reached []string
+	for _, endpoint := range installation.Endpoints {
+		stepTrace.WriteString(fmt.Sprintf("Attempt to reach '%s' from the pod; ", endpoint))
+		cmd := toolkit.Command("curl -s -S -k -m 10 -o /dev/null -w %{http_code} " + endpoint)
+		exitCode, stdOut, stdErr, execErr := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
+		if execErr != nil && exitCode == 0 {
+			err = utils.ReformatError("Could not execute curl in the pod: %v", execErr)
 			break
 		}
+		if egress.ToolMissing(exitCode) {
+			err = utils.ReformatError("curl is not available in the pod (exit code %d). Consider configuring a ToolkitImage.", exitCode)
+			break
+		}
+		// Any HTTP response, including an error status, shows that the service can be reached
+		requests = append(requests, request{endpoint, cmd, exitCode, stdOut, stdErr, exitCode == 0})
+		if exitCode == 0 {
+			reached = append(reached, endpoint)
+		}
 	}
 
 	payload = struct {
-		KubeSystemNamespace    string
-		DashboardPodNamePrefix string
+		PodName      string
+		Namespace    string
+		Resources    []dashboard.Resource
+		Requests     interface{}
+		ToolkitImage string `json:",omitempty"`
 	}{
-		KubeSystemNamespace:    kubeSystemNamespace,
-		DashboardPodNamePrefix: dashboardPodNamePrefix,
+		PodName:      pod.Name,
+		Namespace:    pod.Namespace,
+		Resources:    installation.Resources,
+		Requests:     requests,
+		ToolkitImage: toolkit.Image(),
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(installation.Endpoints) == 0 {
+		stepTrace.WriteString("No dashboard services were found; ")
+	}
+	if len(reached) > 0 {
+		err = utils.ReformatError("The dashboard was reachable from the pod at: %s", strings.Join(reached, ", "))
 	}
+	return err
+}
+
+func (scenario *scenarioState) theKubernetesWebUIServiceAccountIsNotBoundToAPrivilegedRole() error {
+
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
+	stepTrace.WriteString("Find the service accounts used by the dashboard in all namespaces; ")
+	installation, err := dashboard.Find()
+	if err != nil {
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Search cluster role bindings and role bindings for the roles: %s; ", strings.Join(dashboard.PrivilegedRoles, ", ")))
+	bindings, err := dashboard.PrivilegedBindings(installation.ServiceAccounts)
+	payload = struct {
+		ServiceAccounts    []string
+		PrivilegedRoles    []string
+		PrivilegedBindings []dashboard.Binding
+	}{
+		ServiceAccounts:    installation.ServiceAccounts,
+		PrivilegedRoles:    dashboard.PrivilegedRoles,
+		PrivilegedBindings: bindings,
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(bindings) > 0 {
+		var found []string
+		for _, binding := range bindings {
+			found = append(found, fmt.Sprintf("%s '%s' grants '%s' to %s", binding.Kind, binding.Name, binding.Role, binding.ServiceAccount))
+		}
+		err = utils.ReformatError("The dashboard's service account is bound to a privileged role: %s", strings.Join(found, "; "))
+	}
 	return err
 }
 
@@ -106,12 +188,7 @@ func (scenario *scenarioState) theResultOfAProcessInsideThePodEstablishingADirec
 
 	// Standard auditing logic to ensures panics are also audited
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
 	// Guard clause - Validate url
 	if _, urlErr := url.ParseRequestURI(urlAddress); urlErr != nil {
@@ -120,70 +197,352 @@ func (scenario *scenarioState) theResultOfAProcessInsideThePodEstablishingADirec
 	}
 
 	// Guard clause - Ensure pod was created in previous step
-	if len(scenario.pods) == 0 {
+	pod, found := scenario.Pods.First()
+	if !found {
 		err = utils.ReformatError("Pod failed to create in the previous step")
 		return err
 	}
-	podName := scenario.pods[scenario.namespace][0]
+	podName := pod.Name
 
-	// Curl Exit Codes
-	// 6: Couldn't resolve host
-	// 7: Failed to connect
-	// 28: command timed out
-	// 35: SSL connect error
-	expectedExitCodes := []int{6, 7, 28, 35}
+	cmd := toolkit.Command(egress.CurlCommand(urlAddress))
 
-	expectedExitMessage := "Action: Deny" // TODO: This is the AZF response. Consider making this a config option, or extend to include other potential responses.
+	stepTrace.WriteString("Attempt to run curl command in the pod; ")
+	exitCode, stdOut, stdErr, err := connection.State.ExecCommand(cmd, pod.Namespace, podName)
+	attempt := egress.ParseCurl(exitCode, stdOut, stdErr)
 
-	cmd := fmt.Sprintf("curl -m 10 %s", urlAddress) // 10 second timeout should be enough
+	stepTrace.WriteString("Compare the result of the curl command to the egress denial signatures; ")
+	signature, denied, matchErr := egress.MatchDenial(attempt)
 
-	stepTrace.WriteString("Attempt to run curl command in the pod; ")
-	exitCode, stdOut, stdErr, err := connection.State.ExecCommand(cmd, scenario.namespace, podName)
+	payload = struct {
+		PodName          string
+		Namespace        string
+		Command          string
+		ExitCode         int
+		HTTPStatus       int
+		StdOut           string
+		StdErr           string
+		ExecErr          error
+		MatchedSignature string
+		ToolkitImage     string `json:",omitempty"`
+	}{
+		PodName:          podName,
+		Namespace:        pod.Namespace,
+		Command:          cmd,
+		ExitCode:         exitCode,
+		HTTPStatus:       attempt.HTTPStatus,
+		StdOut:           attempt.Body,
+		StdErr:           stdErr,
+		ExecErr:          err,
+		MatchedSignature: signature,
+		ToolkitImage:     toolkit.Image(),
+	}
+
+	switch {
+	case matchErr != nil:
+		err = matchErr
+	case denied:
+		stepTrace.WriteString(fmt.Sprintf("Request was blocked, matching the '%s' denial signature; ", signature))
+		err = nil
+	case err != nil && exitCode == 0:
+		err = utils.ReformatError("Failed to run the curl command in pod '%s': %v", podName, err)
+	case exitCode == 0:
+		err = utils.ReformatError("The request to '%s' reached its destination (HTTP status %d) and did not match any egress denial signature", urlAddress, attempt.HTTPStatus)
+	default:
+		err = utils.ReformatError("Unexpected exit code %d did not match any egress denial signature. Please review audit output for more information.", exitCode)
+	}
+	return err
+}
+
+func (scenario *scenarioState) theResultOfAXConnectionToYIsBlocked(protocol, target string) error {
+	// Supported values for protocol:
+	//	'tcp', 'udp', 'icmp', 'ssh' or 'dns' (DNS-over-HTTPS)
+	// Supported values for target:
+	//	host:port, or host only for icmp
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	// Guard clause - Validate protocol and target
+	command, err := egress.ProtocolCommand(protocol, target)
+	if err != nil {
+		return err
+	}
+
+	// Guard clause - Ensure pod was created in previous step
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return err
+	}
+
+	cmd := toolkit.Command(command)
+	stepTrace.WriteString(fmt.Sprintf("Attempt a %s connection to '%s' from the pod; ", protocol, target))
+	exitCode, stdOut, stdErr, execErr := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
+	reached := egress.Reached(protocol, exitCode, stdOut)
 
 	payload = struct {
-		PodName             string
-		Namespace           string
-		Command             string
-		ExpectedExitCodes   []int
-		ExpectedExitMessage string
-		ExitCode            int
-		StdOut              string
-		StdErr              string
-		ExecErr             error
+		PodName         string
+		Namespace       string
+		Protocol        string
+		Target          string
+		DetectionMethod string
+		Command         string
+		ExitCode        int
+		StdOut          string
+		StdErr          string
+		ExecErr         error
+		Reached         bool
+		ToolkitImage    string `json:",omitempty"`
 	}{
-		PodName:             podName,
-		Namespace:           scenario.namespace,
-		Command:             cmd,
-		ExpectedExitCodes:   expectedExitCodes,
-		ExpectedExitMessage: expectedExitMessage,
-		ExitCode:            exitCode,
-		StdOut:              stdOut,
-		StdErr:              stdErr,
-		ExecErr:             err,
+		PodName:         pod.Name,
+		Namespace:       pod.Namespace,
+		Protocol:        protocol,
+		Target:          target,
+		DetectionMethod: egress.Protocols[protocol],
+		Command:         cmd,
+		ExitCode:        exitCode,
+		StdOut:          stdOut,
+		StdErr:          stdErr,
+		ExecErr:         execErr,
+		Reached:         reached,
+		ToolkitImage:    toolkit.Image(),
 	}
 
-	stepTrace.WriteString("Validate that an expected exit occurred from curl command; ")
+	switch {
+	case reached:
+		err = utils.ReformatError("The %s connection to '%s' was not blocked: %s", protocol, target, egress.Protocols[protocol])
+	case execErr != nil && exitCode == 0:
+		// The command did not run, so the result says nothing about the connection
+		err = utils.ReformatError("Could not execute the %s connection command in the pod: %v", protocol, execErr)
+	case egress.ToolMissing(exitCode):
+		err = utils.ReformatError("The command for the %s connection is not available in the pod (exit code %d). Consider configuring a ToolkitImage.", protocol, exitCode)
+	default:
+		stepTrace.WriteString(fmt.Sprintf("The %s connection failed with exit code %d; ", protocol, exitCode))
+		err = nil
+	}
+	return err
+}
 
-	// Succeed if the expected message was found
-	if strings.Contains(stdOut, expectedExitMessage) {
-		return nil
+// curlAttempt is the part of the audit payload describing a single curl request
+type curlAttempt struct {
+	Command          string
+	ExitCode         int
+	HTTPStatus       int
+	StdOut           string
+	StdErr           string
+	ExecErr          error
+	MatchedSignature string
+}
+
+func (scenario *scenarioState) aRequestToXSucceedsThroughTheEgressProxyAndIsBlockedWithoutIt(urlAddress string) error {
+	// Supported values for urlAddress:
+	//	A valid absolute path URL with http(s) prefix, for a destination approved by the proxy
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	proxy := config.Vars.ServicePacks.Kubernetes.EgressProxy
+	if proxy == "" {
+		// Scenarios tagged with EgressProxyTag are excluded when no proxy is configured, but custom scenarios may not be
+		err = steps.Inconclusive("No EgressProxy is configured, so the proxy cannot be verified")
+		return godog.ErrPending
 	}
 
-	// Validate that no internal error occurred during execution of curl command
-	if stdErr != "" && exitCode == 0 {
-		err = utils.ReformatError("Unknown error raised when attempting to execute '%s' inside container. Please review audit output for more information.", cmd)
+	// Guard clause - Validate url
+	if _, urlErr := url.ParseRequestURI(urlAddress); urlErr != nil {
+		err = utils.ReformatError("Invalid url provided.")
 		return err
 	}
 
-	var exitKnown bool
-	for _, expectedCode := range expectedExitCodes {
-		if exitCode == expectedCode {
-			exitKnown = true
-			err = nil
-		}
+	// Guard clause - Ensure pod was created in previous step
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return err
+	}
+
+	stepTrace.WriteString("Attempt the request through the egress proxy; ")
+	proxied, proxiedAttempt, _ := scenario.curl(toolkit.Command(egress.ProxyCurlCommand(urlAddress, proxy)), pod)
+	proxied.Command = toolkit.Command(egress.ProxyCurlCommand(urlAddress, egress.RedactProxy(proxy)))
+
+	stepTrace.WriteString("Attempt the same request directly; ")
+	direct, _, matchErr := scenario.curl(toolkit.Command(egress.CurlCommand(urlAddress)), pod)
+
+	payload = struct {
+		PodName      string
+		Namespace    string
+		Proxy        string
+		Proxied      curlAttempt
+		Direct       curlAttempt
+		ToolkitImage string `json:",omitempty"`
+	}{
+		PodName:      pod.Name,
+		Namespace:    pod.Namespace,
+		Proxy:        egress.RedactProxy(proxy),
+		Proxied:      proxied,
+		Direct:       direct,
+		ToolkitImage: toolkit.Image(),
+	}
+
+	stepTrace.WriteString("Confirm that the proxied request succeeded; ")
+	succeeded, err := egress.Succeeded(proxiedAttempt)
+	if err != nil {
+		return err
+	}
+	if !succeeded {
+		err = utils.ReformatError("The request to '%s' through the egress proxy did not succeed (exit code %d, HTTP status %d)", urlAddress, proxied.ExitCode, proxied.HTTPStatus)
+		return err
+	}
+
+	stepTrace.WriteString("Confirm that the direct request was blocked; ")
+	switch {
+	case matchErr != nil:
+		err = matchErr
+	case direct.MatchedSignature == "":
+		err = utils.ReformatError("The direct request to '%s' was not blocked (exit code %d, HTTP status %d)", urlAddress, direct.ExitCode, direct.HTTPStatus)
+	}
+	return err
+}
+
+// curl executes a command built by egress.CurlCommand in the pod, and compares its result to the egress denial signatures
+func (scenario *scenarioState) curl(cmd string, pod pods.Pod) (curlAttempt, egress.Attempt, error) {
+	exitCode, stdOut, stdErr, execErr := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
+	attempt := egress.ParseCurl(exitCode, stdOut, stdErr)
+	signature, denied, matchErr := egress.MatchDenial(attempt)
+	if !denied {
+		signature = ""
+	}
+	return curlAttempt{
+		Command:          cmd,
+		ExitCode:         exitCode,
+		HTTPStatus:       attempt.HTTPStatus,
+		StdOut:           attempt.Body,
+		StdErr:           stdErr,
+		ExecErr:          execErr,
+		MatchedSignature: signature,
+	}, attempt, matchErr
+}
+
+// dnsQuery is the audit payload for the DNS steps, holding the resolver's response
+type dnsQuery struct {
+	PodName      string
+	Namespace    string
+	Command      string
+	Resolver     string
+	ExitCode     int
+	Status       string
+	Answers      []string
+	Authority    []string
+	StdOut       string
+	StdErr       string
+	ExecErr      error
+	ToolkitImage string `json:",omitempty"`
+}
+
+// query runs dig in the first pod created in the scenario. An empty resolver uses the cluster's DNS.
+func (scenario *scenarioState) query(recordType, name, resolver string) (response egress.DNSResponse, query dnsQuery, err error) {
+	command, err := egress.DigCommand(recordType, name, resolver)
+	if err != nil {
+		return
+	}
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return
+	}
+
+	cmd := toolkit.Command(command)
+	exitCode, stdOut, stdErr, execErr := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
+	response = egress.ParseDig(exitCode, stdOut)
+	if resolver == "" {
+		resolver = "cluster DNS"
 	}
-	if !exitKnown {
-		err = utils.ReformatError("Unexpected exit code: %d. Please review audit output for more information.", exitCode)
+	query = dnsQuery{
+		PodName:      pod.Name,
+		Namespace:    pod.Namespace,
+		Command:      cmd,
+		Resolver:     resolver,
+		ExitCode:     exitCode,
+		Status:       response.Status,
+		Answers:      response.Answers,
+		Authority:    response.Authority,
+		StdOut:       stdOut,
+		StdErr:       stdErr,
+		ExecErr:      execErr,
+		ToolkitImage: toolkit.Image(),
+	}
+
+	switch {
+	case execErr != nil && exitCode == 0:
+		err = utils.ReformatError("Could not execute dig in the pod: %v", execErr)
+	case egress.ToolMissing(exitCode):
+		err = utils.ReformatError("dig is not available in the pod (exit code %d). Consider configuring a ToolkitImage.", exitCode)
+	}
+	return
+}
+
+func (scenario *scenarioState) thePodCannotResolveThePublicNameXThroughClusterDNS(name string) error {
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString(fmt.Sprintf("Query the cluster's DNS for '%s' from the pod; ", name))
+	response, query, err := scenario.query("A", name, "")
+	payload = query
+	if err != nil {
+		return err
+	}
+
+	stepTrace.WriteString("Confirm that the response contains no answers; ")
+	if response.Resolved() {
+		err = utils.ReformatError("The public name '%s' was resolved through cluster DNS: %s", name, strings.Join(response.Answers, "; "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) thePodCannotQueryTheExternalResolverXDirectly(resolver string) error {
+	// Supported values for resolver:
+	//	host:port
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString(fmt.Sprintf("Query the external resolver '%s' directly from the pod; ", resolver))
+	response, query, err := scenario.query("A", "example.com", resolver)
+	payload = query
+	if err != nil {
+		return err
+	}
+
+	stepTrace.WriteString("Confirm that no reply was received; ")
+	if response.Answered() {
+		err = utils.ReformatError("The external resolver '%s' replied to the query with status %s", resolver, response.Status)
+	}
+	return err
+}
+
+func (scenario *scenarioState) txtLookupsForRandomSubdomainsOfXAreNotForwardedUpstream(domain string) error {
+	// Supported values for domain:
+	//	A public domain whose authoritative servers are reachable from the Internet
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	name := egress.RandomLabel() + "." + domain
+	stepTrace.WriteString(fmt.Sprintf("Query the cluster's DNS for a TXT record of '%s' from the pod; ", name))
+	response, query, err := scenario.query("TXT", name, "")
+	payload = query
+	if err != nil {
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Confirm that the response was not produced by the servers for '%s'; ", domain))
+	if response.AnsweredByZone(domain) {
+		err = utils.ReformatError("The TXT lookup for a random subdomain of '%s' was forwarded upstream and answered with status %s", domain, response.Status)
 	}
 	return err
 }
@@ -199,12 +558,7 @@ func (scenario *scenarioState) podCreationInNamespace(expectedResult, namespace
 
 	// Standard auditing logic to ensures panics are also audited
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
 	// Validate input value
 	var shouldCreatePod bool
@@ -234,9 +588,11 @@ func (scenario *scenarioState) podCreationInNamespace(expectedResult, namespace
 	podObject := constructors.PodSpec(Probe.Name(), ns, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
 
 	stepTrace.WriteString("Create pod from spec; ")
-	createdPodObject, creationErr := scenario.createPodfromObject(podObject)
+	createdPodObject, creationErr := scenario.CreatePodFromObject(podObject)
 
 	stepTrace.WriteString(fmt.Sprintf("Validate pod creation %s; ", expectedResult))
+	var denialReason *admission.DenialReason
+	accepted := true
 	switch shouldCreatePod {
 	case true:
 		if creationErr != nil {
@@ -245,6 +601,8 @@ func (scenario *scenarioState) podCreationInNamespace(expectedResult, namespace
 	case false:
 		if creationErr == nil {
 			err = utils.ReformatError("Pod creation in namespace '%s' succeeded but should have failed", ns)
+		} else {
+			denialReason, accepted = scenario.ValidateDenial(creationErr, &stepTrace)
 		}
 	}
 
@@ -254,14 +612,22 @@ func (scenario *scenarioState) podCreationInNamespace(expectedResult, namespace
 		ExpectedResult string
 		CreatedPod     *apiv1.Pod
 		CreationError  error
+		DenialReason   *admission.DenialReason
+		Mutations      []admission.Mutation
 	}{
 		RequestedPod:   podObject,
 		Namespace:      ns,
 		ExpectedResult: expectedResult,
 		CreatedPod:     createdPodObject,
 		CreationError:  creationErr,
+		DenialReason:   denialReason,
+		Mutations:      admission.Mutations(podObject, createdPodObject),
 	}
 
+	if !accepted {
+		err = steps.Inconclusive("Pod creation was denied by %s, which is not one of the AdmissionControllers", denialReason)
+		return godog.ErrPending
+	}
 	return err
 }
 
@@ -272,7 +638,7 @@ func (probe probeStruct) Name() string {
 
 // Path presents the path of these feature files for external reference
 func (probe probeStruct) Path() string {
-	return probeengine.GetFeaturePath("internal", probe.Name())
+	return features.Path(probe.Name())
 }
 
 // ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
@@ -288,62 +654,21 @@ func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
 
 // ScenarioInitialize provides initialization logic before each scenario is executed
 func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
-
-	ctx.BeforeScenario(func(s *godog.Scenario) {
-		beforeScenario(&scenario, probe.Name(), s)
-	})
-
-	// Background
-	ctx.Step(`^a Kubernetes cluster exists which we can deploy into$`, scenario.aKubernetesClusterIsDeployed)
-
-	// Steps
-	ctx.Step(`^the Kubernetes Web UI is disabled$`, scenario.theKubernetesWebUIIsDisabled)
-	ctx.Step(`^pod creation "([^"]*)" in the "([^"]*)" namespace$`, scenario.podCreationInNamespace)
-	ctx.Step(`^the result of a process inside the pod establishing a direct connection to "([^"]*)" is blocked$`, scenario.theResultOfAProcessInsideThePodEstablishingADirectHTTPConnectionToXIsBlocked)
-
-	ctx.AfterScenario(func(s *godog.Scenario, err error) {
-		afterScenario(scenario, probe, s, err)
-	})
-
-	ctx.BeforeStep(func(st *godog.Step) {
-		scenario.currentStep = st.Text
-	})
-
-	ctx.AfterStep(func(st *godog.Step, err error) {
-		scenario.currentStep = ""
-	})
+	steps.Initialize(ctx, probe.Name())
 }
 
-func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
-	s.name = gs.Name
-	s.probe = summary.State.GetProbeLog(probeName)
-	s.audit = summary.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
-	s.pods = make(map[string][]string)
-	s.namespace = config.Vars.ServicePacks.Kubernetes.ProbeNamespace
-	probeengine.LogScenarioStart(gs)
-}
-
-func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) { // TODO: err is overwitten before first use
-	if config.Vars.ServicePacks.Kubernetes.KeepPods == "false" {
-		for namespace, createdPods := range scenario.pods {
-			for _, podName := range createdPods {
-				err = connection.State.DeletePodIfExists(podName, namespace, probe.Name())
-				if err != nil {
-					log.Printf("[ERROR] Could not retrieve pod from namespace '%s' for deletion: %s", scenario.namespace, err)
-				}
-			}
-		}
-
-	}
-	probeengine.LogScenarioEnd(gs)
-}
-
-func (scenario *scenarioState) createPodfromObject(podObject *apiv1.Pod) (createdPodObject *apiv1.Pod, err error) {
-	createdPodObject, err = connection.State.CreatePodFromObject(podObject, Probe.Name())
-	if createdPodObject != nil && createdPodObject.ObjectMeta.Name != "" {
-		scenario.namespace = createdPodObject.ObjectMeta.Namespace
-		podName := createdPodObject.ObjectMeta.Name
-		scenario.pods[scenario.namespace] = append(scenario.pods[scenario.namespace], podName)
-	}
-	return
+func init() {
+	steps.Register(func(ctx *godog.ScenarioContext) {
+		// Steps
+		ctx.Step(`^the Kubernetes Web UI is disabled$`, scenario.theKubernetesWebUIIsDisabled)
+		ctx.Step(`^the Kubernetes Web UI is not reachable from the pod$`, scenario.theKubernetesWebUIIsNotReachableFromThePod)
+		ctx.Step(`^the Kubernetes Web UI service account is not bound to a privileged role$`, scenario.theKubernetesWebUIServiceAccountIsNotBoundToAPrivilegedRole)
+		ctx.Step(`^pod creation "([^"]*)" in the "([^"]*)" namespace$`, scenario.podCreationInNamespace)
+		ctx.Step(`^the result of a process inside the pod establishing a direct connection to "([^"]*)" is blocked$`, scenario.theResultOfAProcessInsideThePodEstablishingADirectHTTPConnectionToXIsBlocked)
+		ctx.Step(`^the result of a "([^"]*)" connection to "([^"]*)" is blocked$`, scenario.theResultOfAXConnectionToYIsBlocked)
+		ctx.Step(`^a request to "([^"]*)" succeeds through the egress proxy and is blocked without it$`, scenario.aRequestToXSucceedsThroughTheEgressProxyAndIsBlockedWithoutIt)
+		ctx.Step(`^the pod cannot resolve the public name "([^"]*)" through cluster DNS$`, scenario.thePodCannotResolveThePublicNameXThroughClusterDNS)
+		ctx.Step(`^the pod cannot query the external resolver "([^"]*)" directly$`, scenario.thePodCannotQueryTheExternalResolverXDirectly)
+		ctx.Step(`^TXT lookups for random subdomains of "([^"]*)" are not forwarded upstream$`, scenario.txtLookupsForRandomSubdomainsOfXAreNotForwardedUpstream)
+	})
 }
@@ -0,0 +1,184 @@
+package general
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/connection/fake"
+	"github.com/probr/probr-pack-kubernetes/internal/probetest"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// addDashboard adds a dashboard that is reachable from pods, and bound to cluster-admin
+func addDashboard(cluster *fake.Cluster) {
+	cluster.AddDeployment(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubernetes-dashboard", Namespace: "tools"},
+		Spec: appsv1.DeploymentSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			ServiceAccountName: "dashboard",
+			Containers:         []apiv1.Container{{Name: "dashboard", Image: "kubernetesui/dashboard:v2.0.0"}},
+		}}},
+	})
+	cluster.AddService(&apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubernetes-dashboard", Namespace: "tools"},
+		Spec: apiv1.ServiceSpec{
+			ClusterIP: "10.96.0.5",
+			Selector:  map[string]string{"k8s-app": "kubernetes-dashboard"},
+			Ports:     []apiv1.ServicePort{{Port: 443}},
+		},
+	})
+	cluster.AddClusterRoleBinding(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dashboard-admin"},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "dashboard", Namespace: "tools"}},
+	})
+}
+
+func defaultNamespace(pod *apiv1.Pod) bool {
+	return pod.Namespace == "default"
+}
+
+// Replies from dig, for names resolved by a public resolver and for lookups answered by the servers for example.com
+const (
+	digResolved = ";; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: 4711\n\n;; ANSWER SECTION:\nwww.example.com.\t300\tIN\tA\t93.184.216.34\n"
+	digUpstream = ";; ->>HEADER<<- opcode: QUERY, status: NXDOMAIN, id: 4712\n\n;; AUTHORITY SECTION:\nexample.com.\t3600\tIN\tSOA\tns.icann.org. noc.dns.icann.org. 2021 7200 3600 1209600 3600\n"
+)
+
+func TestScenarios(t *testing.T) {
+	var tests = []struct {
+		name     string
+		setup    func(cluster *fake.Cluster)
+		expected map[string][]string
+	}{
+		{
+			name: "every pod is admitted and every command succeeds",
+			expected: map[string][]string{
+				"@k-gen-001": {"Passed"},
+				"@k-gen-002": {"Failed", "Failed", "Failed"},
+				"@k-gen-003": {"Failed", "Failed", "Failed"},
+				// Only ssh and dns require a reply from the destination, which the fake does not provide
+				"@k-gen-004": {"Failed", "Failed", "Failed", "Failed", "Failed", "Failed", "Failed", "Failed", "Failed", "Passed", "Passed", "Passed"},
+				"@k-gen-005": {"Passed", "Passed"},
+				"@k-gen-006": {"Inconclusive"}, // No EgressProxy is configured
+				"@k-gen-007": {"Failed"},
+				"@k-gen-008": {"Passed", "Passed"},
+				"@k-gen-009": {"Passed"},
+				"@k-gen-010": {"Failed"},
+				"@k-gen-011": {"Passed"},
+				"@k-gen-012": {"Passed"},
+			},
+		},
+		{
+			name: "egress is blocked",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`curl`, fake.ExecResult{ExitCode: 28, Stderr: "curl: (28) Connection timed out after 5001 milliseconds"})
+				cluster.OnExec(`nc -z|nc -w`, fake.ExecResult{ExitCode: 1})
+				cluster.OnExec(`dig \+notcp`, fake.ExecResult{ExitCode: 9, Stdout: ";; connection timed out; no servers could be reached"})
+				cluster.OnExec(`ping -c 1`, fake.ExecResult{ExitCode: 1})
+			},
+			expected: map[string][]string{
+				"@k-gen-002": {"Passed"},
+				"@k-gen-003": {"Passed"},
+				"@k-gen-004": {"Passed"},
+			},
+		},
+		{
+			name: "every destination replies, including ssh and DNS over HTTPS servers",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`nc -w 5 github\.com 22`, fake.ExecResult{Stdout: "SSH-2.0-babeld-d48f2b8c\n"})
+				cluster.OnExec(`dns-query`, fake.ExecResult{Stdout: `{"Status":0,"Answer":[{"name":"example.com.","type":1,"data":"93.184.216.34"}]}`})
+			},
+			expected: map[string][]string{"@k-gen-004": {"Failed"}},
+		},
+		{
+			name: "DNS queries are answered from the Internet",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`dig .* TXT `, fake.ExecResult{Stdout: digUpstream})
+				cluster.OnExec(`dig`, fake.ExecResult{Stdout: digResolved})
+			},
+			expected: map[string][]string{
+				"@k-gen-005": {"Failed"},
+				"@k-gen-008": {"Failed"},
+				"@k-gen-009": {"Failed"},
+			},
+		},
+		{
+			name:  "dashboard is installed",
+			setup: addDashboard,
+			expected: map[string][]string{
+				"@k-gen-001": {"Failed"},
+				"@k-gen-011": {"Failed"},
+				"@k-gen-012": {"Failed"},
+			},
+		},
+		{
+			name: "dashboard is installed but unreachable",
+			setup: func(cluster *fake.Cluster) {
+				addDashboard(cluster)
+				cluster.OnExec(`curl .*10\.96\.0\.5`, fake.ExecResult{ExitCode: 28, Stderr: "Connection timed out"})
+			},
+			expected: map[string][]string{
+				"@k-gen-001": {"Failed"},
+				"@k-gen-011": {"Passed"},
+				"@k-gen-012": {"Failed"},
+			},
+		},
+		{
+			name: "default namespace is denied",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`admission webhook "validate.kyverno.svc" denied the request: resource Pod/default/probe was blocked due to the following policies disallow-default-namespace: validate-namespace: Using 'default' namespace is not allowed`, defaultNamespace)
+			},
+			expected: map[string][]string{"@k-gen-010": {"Passed"}},
+		},
+		{
+			name: "default namespace is denied by quota",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`pods "probe" is forbidden: exceeded quota: default-quota, requested: pods=1, used: pods=0, limited: pods=0`, defaultNamespace)
+			},
+			expected: map[string][]string{"@k-gen-010": {"Inconclusive"}},
+		},
+		{
+			name: "pod to pod connection is prevented",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`nc -l -p 8080`, fake.ExecResult{ExitCode: 143})
+				cluster.OnExec(`nc -z -w 5 10\.0\.0\.\d+ 8080`, fake.ExecResult{ExitCode: 1, Stderr: "nc: 10.0.0.2 (10.0.0.2:8080): Operation timed out"})
+			},
+			expected: map[string][]string{"@k-gen-007": {"Passed"}},
+		},
+		{
+			name: "pod to pod listener did not run",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`nc -l -p 8080`, fake.ExecResult{ExitCode: 1, Stderr: "nc: bind: Address already in use"})
+				cluster.OnExec(`nc -z`, fake.ExecResult{ExitCode: 1})
+			},
+			expected: map[string][]string{"@k-gen-007": {"Failed"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := fake.NewCluster()
+			if test.setup != nil {
+				test.setup(cluster)
+			}
+			audit := probetest.RunAll(t, Probe, cluster)
+			probetest.ExpectResults(t, audit, test.expected)
+		})
+	}
+}
+
+func TestEgressExecErrorIsReported(t *testing.T) {
+	cluster := fake.NewCluster()
+	cluster.OnExec(`curl`, fake.ExecResult{ExitCode: 0, Err: errors.New("error dialing backend: EOF")})
+
+	audit := probetest.Run(t, Probe, cluster, "@k-gen-002")
+	probetest.Expect(t, audit, "@k-gen-002", "Failed")
+	for _, scenario := range audit.Scenarios {
+		step := scenario.Steps[len(scenario.Steps)]
+		if !strings.Contains(step.Error, "error dialing backend: EOF") {
+			t.Errorf("Expected the exec error to be audited for '%s', found: %s", scenario.Name, step.Error)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package metrics exposes the results of continuous probe runs in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/probr/probr-pack-kubernetes/internal/summary"
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// CISTagPrefix is used by feature files to reference the CIS benchmark control covered by a scenario, such as @cis-5.2.5
+const CISTagPrefix = "@cis-"
+
+// Scenario results reported by the result label of each scenario gauge, in order of precedence
+const (
+	resultPassed       = "passed"
+	resultInconclusive = "inconclusive"
+	resultFailed       = "failed"
+)
+
+// resultRank orders the scenario results, so that outline examples sharing the same labels report the worst result
+var resultRank = map[string]int{resultPassed: 0, resultInconclusive: 1, resultFailed: 2}
+
+// controlTag matches the scenario identifiers used in the feature files, such as @k-pod-001
+var controlTag = regexp.MustCompile(`^@k-[a-z]+-\d+$`)
+
+// Counter is a monotonically increasing value that is safe for concurrent use
+type Counter struct {
+	value uint64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+var (
+	// PodsCreated counts every pod successfully created by a probe
+	PodsCreated Counter
+
+	// PodCleanupFailures counts every probe pod that could not be deleted after its scenario
+	PodCleanupFailures Counter
+)
+
+// scenarioLabels identifies a single scenario gauge
+type scenarioLabels struct {
+	probe    string
+	scenario string
+	tag      string
+	cisID    string
+}
+
+// lastRun holds the results of the most recent completed run
+type lastRun struct {
+	mutex     sync.RWMutex
+	started   time.Time
+	completed time.Time
+	duration  time.Duration
+	err       error
+	scenarios map[scenarioLabels]string
+}
+
+var last lastRun
+
+// staleAfter is the time since the latest run started after which the daemon is reported as unhealthy
+var staleAfter time.Duration
+
+// RecordStart marks the start of a run, showing that the daemon's run loop is still scheduling runs
+func RecordStart() {
+	last.mutex.Lock()
+	defer last.mutex.Unlock()
+	last.started = time.Now()
+}
+
+// RecordRun replaces the scenario gauges with the results of a completed run
+func RecordRun(state *audit.SummaryState, duration time.Duration, runErr error) {
+	scenarios := make(map[scenarioLabels]string)
+	for probeName, probe := range state.Probes {
+		for _, scenario := range probe.Scenarios {
+			var result string
+			switch scenario.Result {
+			case "Passed", "Waived":
+				result = resultPassed
+			case summary.InconclusiveResult:
+				result = resultInconclusive
+			case "Failed":
+				result = resultFailed
+			default:
+				continue // Scenarios that did not run to completion are not reported
+			}
+			labels := scenarioLabels{probe: probeName, scenario: scenario.Name}
+			for _, tag := range scenario.Tags {
+				if controlTag.MatchString(tag) {
+					labels.tag = tag
+				} else if strings.HasPrefix(tag, CISTagPrefix) {
+					labels.cisID = strings.TrimPrefix(tag, CISTagPrefix)
+				}
+			}
+			// Outline examples share the same labels; the gauge only passes if every example passed
+			if previous, found := scenarios[labels]; found && resultRank[previous] > resultRank[result] {
+				result = previous
+			}
+			scenarios[labels] = result
+		}
+	}
+
+	last.mutex.Lock()
+	defer last.mutex.Unlock()
+	last.completed = time.Now()
+	last.duration = duration
+	last.err = runErr
+	last.scenarios = scenarios
+}
+
+// Serve starts an HTTP server exposing /metrics and /healthz on the provided address. The daemon is reported
+// as unhealthy if no run has started within twice the run interval, such as when a run has hung.
+func Serve(address string, interval time.Duration) {
+	staleAfter = 2 * interval
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Write(w)
+	})
+	mux.HandleFunc("/healthz", healthz)
+
+	log.Printf("[INFO] Serving metrics on %s", address)
+	err := http.ListenAndServe(address, mux)
+	if err != nil {
+		log.Fatalf("[ERROR] Metrics server failed: %v", err)
+	}
+}
+
+// healthz reports the health of the daemon itself, returning 503 if the run loop has stopped scheduling runs.
+// The result and any error of the last run are included for information, but failing controls never return 503,
+// so that they do not restart the daemon.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	last.mutex.RLock()
+	defer last.mutex.RUnlock()
+
+	health := struct {
+		Status           string
+		LastRunStarted   *time.Time `json:",omitempty"`
+		LastRunCompleted *time.Time `json:",omitempty"`
+		LastRunResult    string     `json:",omitempty"`
+		LastRunError     string     `json:",omitempty"`
+	}{
+		Status: "OK",
+	}
+	if !last.started.IsZero() {
+		health.LastRunStarted = &last.started
+	}
+	if !last.completed.IsZero() {
+		health.LastRunCompleted = &last.completed
+		health.LastRunResult = "Success"
+		if last.err != nil {
+			health.LastRunResult = "Failed"
+			health.LastRunError = last.err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !last.started.IsZero() && time.Since(last.started) > staleAfter {
+		health.Status = fmt.Sprintf("No run has started for %s", time.Since(last.started).Round(time.Second))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(utils.JSON(health))
+}
+
+// Write outputs all metrics in the Prometheus text exposition format
+func Write(w io.Writer) {
+	last.mutex.RLock()
+	defer last.mutex.RUnlock()
+
+	fmt.Fprintln(w, "# HELP probr_scenario_passed Whether the scenario passed (1) or not (0) during the last run. The result label is passed, failed or inconclusive.")
+	fmt.Fprintln(w, "# TYPE probr_scenario_passed gauge")
+	var labels []scenarioLabels
+	for l := range last.scenarios {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].probe+labels[i].scenario < labels[j].probe+labels[j].scenario
+	})
+	for _, l := range labels {
+		result, value := last.scenarios[l], 0
+		if result == resultPassed {
+			value = 1
+		}
+		fmt.Fprintf(w, "probr_scenario_passed{probe=\"%s\",scenario=\"%s\",tag=\"%s\",cis_id=\"%s\",result=\"%s\"} %d\n",
+			escape(l.probe), escape(l.scenario), escape(l.tag), escape(l.cisID), result, value)
+	}
+
+	success, completed := 1, int64(0)
+	if last.err != nil {
+		success = 0
+	}
+	if !last.completed.IsZero() {
+		completed = last.completed.Unix()
+	}
+	writeMetric(w, "probr_last_run_success", "gauge", "Whether the last run completed without blocking failures.", success)
+	writeMetric(w, "probr_last_run_timestamp_seconds", "gauge", "Unix time at which the last run completed.", completed)
+	writeMetric(w, "probr_run_duration_seconds", "gauge", "Duration of the last run.", last.duration.Seconds())
+	writeMetric(w, "probr_pods_created_total", "counter", "Pods created by probes since the process started.", PodsCreated.Value())
+	writeMetric(w, "probr_pod_cleanup_failures_total", "counter", "Probe pods that could not be deleted since the process started.", PodCleanupFailures.Value())
+}
+
+func writeMetric(w io.Writer, name, metricType, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	fmt.Fprintf(w, "%s %v\n", name, value)
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	messages "github.com/cucumber/messages-go/v10"
+	audit "github.com/probr/probr-sdk/audit"
+)
+
+func TestRecordRunReportsInconclusiveScenarios(t *testing.T) {
+	state := audit.NewSummaryState("kubernetes")
+	general := state.GetProbeLog("general")
+	tags := []*messages.Pickle_PickleTag{{Name: "@k-gen-006"}}
+	for _, result := range []string{"Passed", "Inconclusive", "Passed"} {
+		general.InitializeAuditor("Test outgoing connectivity through the egress proxy", tags).Result = result
+	}
+	RecordRun(&state, time.Second, nil)
+
+	var output bytes.Buffer
+	Write(&output)
+	expected := `probr_scenario_passed{probe="general",scenario="Test outgoing connectivity through the egress proxy",tag="@k-gen-006",cis_id="",result="inconclusive"} 0`
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("Expected the outline to be reported as inconclusive, found:\n%s", output.String())
+	}
+}
+
+func TestHealthzReportsLastRun(t *testing.T) {
+	staleAfter = time.Hour
+	RecordStart()
+	RecordRun(&audit.SummaryState{}, time.Second, errors.New("2 scenarios regressed since the baseline run"))
+
+	recorder := httptest.NewRecorder()
+	healthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected a failed run to leave the daemon healthy, found status %d", recorder.Code)
+	}
+	var health struct {
+		LastRunResult string
+		LastRunError  string
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.LastRunResult != "Failed" || health.LastRunError != "2 scenarios regressed since the baseline run" {
+		t.Errorf("Expected the last run's result and error, found: %s", recorder.Body.String())
+	}
+
+	last.started = time.Now().Add(-2 * time.Hour)
+	recorder = httptest.NewRecorder()
+	healthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a stalled run loop to return 503, found status %d", recorder.Code)
+	}
+}
@@ -0,0 +1,60 @@
+@k-psa
+@probes/kubernetes/pod_security_admission
+@k8s-gte-1.23
+Feature: Enforce Pod Security Standards with Pod Security Admission
+    As a Security Auditor
+    I want to ensure that Pod Security Admission is configured for every namespace in my organisation's Kubernetes clusters
+    So that pods which do not meet our Pod Security Standard are rejected, now that Pod Security Policy has been removed
+
+    Security Standard References:
+        https://kubernetes.io/docs/concepts/security/pod-security-admission/
+
+    Background:
+        Given a Kubernetes cluster exists which we can deploy into
+
+    @k-psa-001
+    @cis-6.10.3
+    @severity-high
+    @cluster-scope
+    Scenario: Ensure every namespace enforces the minimum Pod Security Standard
+
+        Namespaces without a pod-security.kubernetes.io/enforce label, or with a level below
+        PodSecurityMinimumLevel, admit pods that do not meet the organisation's Pod Security Standard.
+        Namespaces listed in PodSecurityExemptNamespaces are not checked.
+
+        Security Standard References:
+            - CIS Kubernetes Benchmark v1.6.0 - 6.10.3
+
+        Then every namespace sets the "enforce" Pod Security level to at least the minimum
+
+    @k-psa-002
+    @cis-6.10.3
+    @severity-low
+    @cluster-scope
+    Scenario Outline: Ensure every namespace reports violations of the minimum Pod Security Standard
+
+        The audit and warn modes record and display violations that are not enforced,
+        so that non-compliant workloads are visible before a stricter level is enforced.
+
+        Security Standard References:
+            - CIS Kubernetes Benchmark v1.6.0 - 6.10.3
+
+        Then every namespace sets the "<MODE>" Pod Security level to at least the minimum
+
+        Examples:
+            | MODE  |
+            | audit |
+            | warn  |
+
+    @k-psa-003
+    @cis-6.10.3
+    @severity-high
+    Scenario: Ensure Pod Security Admission denies pods that violate the minimum Pod Security Standard
+
+        A namespace label has no effect if the PodSecurity admission plugin is disabled,
+        so a violating pod is created in the probe namespace to confirm that Pod Security Admission denies it.
+
+        Security Standard References:
+            - CIS Kubernetes Benchmark v1.6.0 - 6.10.3
+
+        Then pod creation violating the minimum Pod Security level is denied by Pod Security Admission
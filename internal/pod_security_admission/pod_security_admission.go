@@ -0,0 +1,257 @@
+// Package psa provides the implementation required to execute the BDD tests described in pod_security_admission.feature file
+package psa
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cucumber/godog"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/features"
+	"github.com/probr/probr-pack-kubernetes/internal/steps"
+	"github.com/probr/probr-sdk/providers/kubernetes/constructors"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// labelPrefix is used by the Pod Security Admission labels, such as pod-security.kubernetes.io/enforce
+const labelPrefix = "pod-security.kubernetes.io/"
+
+// Levels are the Pod Security Standards, from least to most restrictive
+var Levels = []string{"privileged", "baseline", "restricted"}
+
+// Modes are the Pod Security Admission modes that can be set for a namespace
+var Modes = []string{"enforce", "audit", "warn"}
+
+var version = regexp.MustCompile(`^(latest|v1\.[0-9]+)$`)
+
+type probeStruct struct{}
+
+// scenarioState provides the step definitions for this probe, using the state shared by all probes
+type scenarioState struct {
+	*steps.ScenarioState
+}
+
+// Probe meets the service pack interface for adding the logic from this file
+var Probe probeStruct
+var scenario = scenarioState{&steps.Scenario}
+
+// namespaceLevel describes the Pod Security Admission labels of a namespace for a single mode
+type namespaceLevel struct {
+	Namespace string
+	Level     string `json:",omitempty"`
+	Version   string `json:",omitempty"`
+	Problem   string `json:",omitempty"`
+}
+
+func (scenario *scenarioState) everyNamespaceSetsTheXPodSecurityLevelToAtLeastTheMinimum(mode string) error {
+	// Supported values for 'mode':
+	//	'enforce'
+	//	'audit'
+	//	'warn'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	if !contains(Modes, mode) {
+		err = utils.ReformatError("Unexpected value provided for Pod Security Admission mode: '%s' Expected values: %v", mode, Modes)
+		return err
+	}
+	minimum, err := minimumLevel()
+	if err != nil {
+		return err
+	}
+
+	stepTrace.WriteString("List all namespaces; ")
+	namespaces, err := connection.State.GetNamespaces()
+	if err != nil {
+		err = utils.ReformatError("Failed to list namespaces: %v", err)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Compare the '%s' level of each namespace not in PodSecurityExemptNamespaces with '%s'; ", mode, minimum))
+	var levels []namespaceLevel
+	var exempt, noncompliant []string
+	for _, namespace := range namespaces.Items {
+		if contains(config.Vars.ServicePacks.Kubernetes.PodSecurityExemptNamespaces, namespace.Name) {
+			exempt = append(exempt, namespace.Name)
+			continue
+		}
+		level := labelledLevel(namespace, mode, minimum)
+		if level.Problem != "" {
+			noncompliant = append(noncompliant, fmt.Sprintf("%s (%s)", level.Namespace, level.Problem))
+		}
+		levels = append(levels, level)
+	}
+
+	payload = struct {
+		Mode             string
+		MinimumLevel     string
+		Namespaces       []namespaceLevel
+		ExemptNamespaces []string
+	}{
+		Mode:             mode,
+		MinimumLevel:     minimum,
+		Namespaces:       levels,
+		ExemptNamespaces: exempt,
+	}
+
+	if len(noncompliant) > 0 {
+		err = utils.ReformatError("%d namespace(s) do not %s the '%s' Pod Security level: %s", len(noncompliant), mode, minimum, strings.Join(noncompliant, "; "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) podCreationViolatingTheMinimumPodSecurityLevelIsDeniedByPodSecurityAdmission() error {
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
+
+	minimum, err := minimumLevel()
+	if err != nil {
+		return err
+	}
+	if minimum == "privileged" {
+		stepTrace.WriteString("The 'privileged' level allows every pod, so there is no violating pod to create; ")
+		err = steps.Inconclusive("The minimum Pod Security level is 'privileged', so no pod violates it")
+		return godog.ErrPending
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Build a pod spec that violates the '%s' Pod Security Standard; ", minimum))
+	podObject := violatingPod(minimum)
+
+	stepTrace.WriteString("Create pod from spec; ")
+	createdPodObject, creationErr := scenario.CreatePodFromObject(podObject)
+
+	stepTrace.WriteString("Validate pod creation was denied by Pod Security Admission; ")
+	var denialReason *admission.DenialReason
+	if creationErr == nil {
+		err = utils.ReformatError("Pod creation in namespace '%s' succeeded, but should have been denied by Pod Security Admission", podObject.Namespace)
+	} else {
+		denialReason = admission.ParseDenial(creationErr)
+		scenario.Denial = denialReason
+		stepTrace.WriteString(fmt.Sprintf("Denied by %s; ", denialReason))
+		enforced := strings.SplitN(denialReason.Policy, ":", 2)[0]
+		if denialReason.Controller != admission.PodSecurity {
+			err = utils.ReformatError("Pod creation was denied by %s, not Pod Security Admission", denialReason)
+		} else if rank(enforced) < rank(minimum) {
+			err = utils.ReformatError("Pod Security Admission enforced the '%s' level, which is less restrictive than '%s'", enforced, minimum)
+		}
+	}
+
+	payload = struct {
+		MinimumLevel  string
+		RequestedPod  *apiv1.Pod
+		CreatedPod    *apiv1.Pod
+		CreationError error
+		DenialReason  *admission.DenialReason
+		Mutations     []admission.Mutation
+	}{
+		MinimumLevel:  minimum,
+		RequestedPod:  podObject,
+		CreatedPod:    createdPodObject,
+		CreationError: creationErr,
+		DenialReason:  denialReason,
+		Mutations:     admission.Mutations(podObject, createdPodObject),
+	}
+	return err
+}
+
+// Name presents the name of this probe for external reference
+func (probe probeStruct) Name() string {
+	return "pod_security_admission"
+}
+
+// Path presents the path of these feature files for external reference
+func (probe probeStruct) Path() string {
+	return features.Path(probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+	ctx.BeforeSuite(func() {
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize provides initialization logic before each scenario is executed
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+	steps.Initialize(ctx, probe.Name())
+}
+
+func init() {
+	steps.Register(func(ctx *godog.ScenarioContext) {
+		// Steps
+		ctx.Step(`^every namespace sets the "([^"]*)" Pod Security level to at least the minimum$`, scenario.everyNamespaceSetsTheXPodSecurityLevelToAtLeastTheMinimum)
+		ctx.Step(`^pod creation violating the minimum Pod Security level is denied by Pod Security Admission$`, scenario.podCreationViolatingTheMinimumPodSecurityLevelIsDeniedByPodSecurityAdmission)
+	})
+}
+
+// minimumLevel returns the configured PodSecurityMinimumLevel, or an error if it is not a Pod Security Standard
+func minimumLevel() (string, error) {
+	minimum := config.Vars.ServicePacks.Kubernetes.PodSecurityMinimumLevel
+	if rank(minimum) < 0 {
+		return minimum, utils.ReformatError("Unexpected value provided for PodSecurityMinimumLevel: '%s' Expected values: %v", minimum, Levels)
+	}
+	return minimum, nil
+}
+
+// labelledLevel reads the level and version labels of the namespace for the mode, describing any problem
+// with them. A missing version label is equivalent to 'latest'.
+func labelledLevel(namespace apiv1.Namespace, mode, minimum string) namespaceLevel {
+	level := namespaceLevel{
+		Namespace: namespace.Name,
+		Level:     namespace.Labels[labelPrefix+mode],
+		Version:   namespace.Labels[labelPrefix+mode+"-version"],
+	}
+	switch {
+	case level.Level == "":
+		level.Problem = fmt.Sprintf("no %s%s label", labelPrefix, mode)
+	case rank(level.Level) < 0:
+		level.Problem = fmt.Sprintf("unknown level '%s'", level.Level)
+	case rank(level.Level) < rank(minimum):
+		level.Problem = fmt.Sprintf("level '%s'", level.Level)
+	case level.Version != "" && !version.MatchString(level.Version):
+		level.Problem = fmt.Sprintf("invalid version '%s'", level.Version)
+	}
+	return level
+}
+
+// violatingPod returns a pod in the ProbeNamespace that violates the level, along with every more restrictive level.
+// The default pod spec meets the baseline standard but not the restricted standard, as it does not
+// require a non-root user, drop all capabilities or set a seccomp profile in its security context.
+func violatingPod(level string) *apiv1.Pod {
+	pod := constructors.PodSpec(Probe.Name(), config.Vars.ServicePacks.Kubernetes.ProbeNamespace, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
+	if level == "baseline" {
+		pod.Spec.Containers[0].SecurityContext.Privileged = utils.BoolPtr(true)
+		pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = utils.BoolPtr(true)
+	}
+	return pod
+}
+
+// rank returns the position of the level in Levels, or -1 if it is not a Pod Security Standard
+func rank(level string) int {
+	for i, known := range Levels {
+		if level == known {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package psa
+
+import (
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/connection/fake"
+	"github.com/probr/probr-pack-kubernetes/internal/probetest"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func namespace(name string, labels map[string]string) *apiv1.Namespace {
+	return &apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+// labelNamespaces adds namespaces that set every mode to the level, apart from the exempt kube-system
+func labelNamespaces(cluster *fake.Cluster, level string) {
+	labels := map[string]string{}
+	for _, mode := range Modes {
+		labels[labelPrefix+mode] = level
+	}
+	cluster.SetServerVersion("v1.25.3")
+	cluster.AddNamespace(namespace("kube-system", nil))
+	cluster.AddNamespace(namespace("default", labels))
+	cluster.AddNamespace(namespace("apps", labels))
+}
+
+func privileged(pod *apiv1.Pod) bool {
+	sc := pod.Spec.Containers[0].SecurityContext
+	return sc != nil && sc.Privileged != nil && *sc.Privileged
+}
+
+func TestScenarios(t *testing.T) {
+	var tests = []struct {
+		name     string
+		setup    func(cluster *fake.Cluster)
+		expected map[string][]string
+	}{
+		{
+			name: "empty cluster",
+			expected: map[string][]string{
+				"@k-psa-001": {"Passed"},
+				"@k-psa-002": {"Passed", "Passed"},
+				"@k-psa-003": {"Failed"},
+			},
+		},
+		{
+			name:  "namespaces meet the minimum level",
+			setup: func(cluster *fake.Cluster) { labelNamespaces(cluster, "restricted") },
+			expected: map[string][]string{
+				"@k-psa-001": {"Passed"},
+				"@k-psa-002": {"Passed", "Passed"},
+			},
+		},
+		{
+			name:  "namespaces are below the minimum level",
+			setup: func(cluster *fake.Cluster) { labelNamespaces(cluster, "privileged") },
+			expected: map[string][]string{
+				"@k-psa-001": {"Failed"},
+				"@k-psa-002": {"Failed", "Failed"},
+			},
+		},
+		{
+			name: "namespace without labels",
+			setup: func(cluster *fake.Cluster) {
+				labelNamespaces(cluster, "baseline")
+				cluster.AddNamespace(namespace("unlabelled", nil))
+			},
+			expected: map[string][]string{"@k-psa-001": {"Failed"}},
+		},
+		{
+			name: "violating pod is denied",
+			setup: func(cluster *fake.Cluster) {
+				labelNamespaces(cluster, "baseline")
+				cluster.Deny(`violates PodSecurity "baseline:latest": privileged (container "probr" must not set securityContext.privileged=true)`, privileged)
+			},
+			expected: map[string][]string{"@k-psa-003": {"Passed"}},
+		},
+		{
+			name:     "violating pod is admitted",
+			setup:    func(cluster *fake.Cluster) { labelNamespaces(cluster, "baseline") },
+			expected: map[string][]string{"@k-psa-003": {"Failed"}},
+		},
+		{
+			name: "violating pod is denied by another control",
+			setup: func(cluster *fake.Cluster) {
+				labelNamespaces(cluster, "baseline")
+				cluster.Deny(`admission webhook "validation.gatekeeper.sh" denied the request: [psp-privileged-container] Privileged container is not allowed`, func(*apiv1.Pod) bool { return true })
+			},
+			expected: map[string][]string{"@k-psa-003": {"Failed"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := fake.NewCluster()
+			if test.setup != nil {
+				test.setup(cluster)
+			}
+			audit := probetest.RunAll(t, Probe, cluster)
+			probetest.ExpectResults(t, audit, test.expected)
+		})
+	}
+}
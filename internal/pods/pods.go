@@ -0,0 +1,91 @@
+// Package pods tracks the pods created during a scenario, so that steps can refer to them by name
+// and all of them can be deleted when the scenario ends
+package pods
+
+import (
+	"log"
+
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/metrics"
+	"github.com/probr/probr-pack-kubernetes/internal/toolkit"
+	"github.com/probr/probr-sdk/utils"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Pod identifies a pod created during a scenario
+type Pod struct {
+	Alias     string // The name used to refer to the pod in feature files, if any
+	Name      string
+	Namespace string
+}
+
+// Tracker holds the pods created during a single scenario, in the order they were created
+type Tracker struct {
+	pods []Pod
+}
+
+// NewTracker returns an empty tracker, for use at the start of a scenario
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Create creates the pod and tracks it under the provided alias. An empty alias is allowed for unnamed pods.
+// The probe toolkit is added to the pod if one has been configured.
+func (t *Tracker) Create(alias string, pod *apiv1.Pod, probeName string) (created *apiv1.Pod, err error) {
+	if alias != "" {
+		if _, exists := t.Get(alias); exists {
+			return nil, utils.ReformatError("A pod named '%s' was already created in this scenario", alias)
+		}
+	}
+	toolkit.Inject(pod)
+	created, err = connection.State.CreatePodFromObject(pod, probeName)
+	if created != nil && created.ObjectMeta.Name != "" {
+		metrics.PodsCreated.Inc()
+		t.pods = append(t.pods, Pod{Alias: alias, Name: created.ObjectMeta.Name, Namespace: created.ObjectMeta.Namespace})
+	}
+	return
+}
+
+// Get returns the pod that was created with the provided alias
+func (t *Tracker) Get(alias string) (pod Pod, found bool) {
+	for _, p := range t.pods {
+		if p.Alias == alias {
+			return p, true
+		}
+	}
+	return
+}
+
+// Named returns the pod that was created with the provided alias, or an error that can be audited by the step
+func (t *Tracker) Named(alias string) (Pod, error) {
+	pod, found := t.Get(alias)
+	if !found {
+		return pod, utils.ReformatError("No pod named '%s' was created in a previous step", alias)
+	}
+	return pod, nil
+}
+
+// First returns the first pod created in the scenario, for steps that do not refer to a pod by name
+func (t *Tracker) First() (pod Pod, found bool) {
+	if len(t.pods) == 0 {
+		return
+	}
+	return t.pods[0], true
+}
+
+// Count returns the number of pods created in the scenario
+func (t *Tracker) Count() int {
+	return len(t.pods)
+}
+
+// DeleteAll deletes every pod created in the scenario, logging any pods that could not be deleted
+func (t *Tracker) DeleteAll(probeName string) {
+	for _, pod := range t.pods {
+		err := connection.State.DeletePodIfExists(pod.Name, pod.Namespace, probeName)
+		if err != nil {
+			log.Printf("[ERROR] Could not retrieve pod from namespace '%s' for deletion: %s", pod.Namespace, err)
+			metrics.PodCleanupFailures.Inc()
+		}
+	}
+	t.pods = nil
+}
@@ -10,6 +10,8 @@ Feature: Pod Security
         Given a Kubernetes cluster exists which we can deploy into
 
     @k-pod-001
+    @cis-5.2.5
+    @severity-critical
     Scenario: Prevent a deployment from running with privileged access
 
         Pods that request Privileged mode (using the security context of the container spec)
@@ -21,9 +23,11 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.2.5
 
         Then pod creation "succeeds" with "allowPrivilegeEscalation" set to "false" in the pod spec
-        And pod creation "fails" with "allowPrivilegeEscalation" set to "true" in the pod spec
+        And pod creation "fails or is mutated to compliant" with "allowPrivilegeEscalation" set to "true" in the pod spec
 
     @k-pod-002
+    @cis-5.2.5
+    @severity-high
     Scenario Outline: Prevent execution of commands that require privileged access
 
         By default pods that don't specify whether Privileged mode is set within the security context
@@ -43,6 +47,8 @@ Feature: Pod Security
             | false                     |
 
     @k-pod-003
+    @cis-5.2.2
+    @severity-critical
     Scenario: Prevent a deployment from running in the host's process tree namespace
 
         HostPID controls whether a pod's containers can share the host process ID namespace.
@@ -53,9 +59,11 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.2.2
 
         When pod creation "succeeds" with "hostPID" set to "false" in the pod spec
-        Then pod creation "fails" with "hostPID" set to "true" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "hostPID" set to "true" in the pod spec
 
     @k-pod-004
+    @cis-5.2.2
+    @severity-high
     Scenario Outline: Prevent execution of commands that allow privileged access
 
         By default pods that don't specify a value for hostPID should not have the ability to
@@ -75,6 +83,8 @@ Feature: Pod Security
             | false                     |
 
     @k-pod-005
+    @cis-5.2.3
+    @severity-high
     Scenario: Prevent a deployment from running with access to the shared host IPC namespace
 
         HostIPC controls whether a pod's containers can share the host IPC namespace, 
@@ -85,9 +95,11 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.2.3
 
         When pod creation "succeeds" with "hostIPC" set to "false" in the pod spec
-        Then pod creation "fails" with "hostIPC" set to "true" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "hostIPC" set to "true" in the pod spec
 
     @k-pod-006
+    @cis-5.2.3
+    @severity-medium
     Scenario Outline: Prevent a deployment from running with access to the shared host IPC namespace
 
         By default pods that don't specify whether Host IPC namespace mode is set should not
@@ -107,6 +119,8 @@ Feature: Pod Security
             | false                     |
 
     @k-pod-007
+    @cis-5.2.4
+    @severity-high
     Scenario: Prevent a deployment from running with access to the host's network namespace
 
         The HostNetwork flag controls whether the pod may use the node network namespace. Doing so gives the pod access
@@ -118,9 +132,11 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.2.4
 
         When pod creation "succeeds" with "hostNetwork" set to "false" in the pod spec
-        Then pod creation "fails" with "hostNetwork" set to "true" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "hostNetwork" set to "true" in the pod spec
 
     @k-pod-008
+    @cis-5.2.4
+    @severity-medium
     Scenario Outline: Prevent execution of commands that allow access to the host's network namespace access
 
         By default pods that don't specify whether access to host's network namespace is required should not be able to access the host's network namespace.
@@ -139,6 +155,8 @@ Feature: Pod Security
             | false                     |
 
     @k-pod-009
+    @cis-5.2.6
+    @severity-high
     Scenario: Prevent a deployment from running as the root user
 
         The root user (0) should be avoided in order to ensure least privilege.
@@ -148,9 +166,11 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.2.6
 
         When pod creation "succeeds" with "user" set to "1000" in the pod spec
-        Then pod creation "fails" with "user" set to "0" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "user" set to "0" in the pod spec
 
     @k-pod-010
+    @cis-5.2.6
+    @severity-medium
     Scenario: Prevent usage of commands that require root permissions
 
         By default pods that don't specify which user to run as should not allow execution of commands as root user
@@ -163,6 +183,9 @@ Feature: Pod Security
         Then the execution of a "root" command inside the pod is "prevented"
 
     @k-pod-011
+    @cis-5.7.2
+    @severity-medium
+    @k8s-lt-1.25
     Scenario: Ensure that the seccomp profile is set to docker/default in all pod definitions
 
         Seccomp (secure computing mode) is used to restrict the set of system calls applications can make,
@@ -174,9 +197,26 @@ Feature: Pod Security
             - CIS Kubernetes Benchmark v1.6.0 - 5.7.2
 
         When pod creation "succeeds" with "annotations" set to "include seccomp profile" in the pod spec
-        Then pod creation "fails" with "annotations" set to "not include seccomp profile" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "annotations" set to "not include seccomp profile" in the pod spec
+
+    @k-pod-011
+    @cis-5.7.2
+    @severity-medium
+    @k8s-gte-1.25
+    Scenario: Ensure that the seccomp profile is set to RuntimeDefault in all pod definitions
+
+        From Kubernetes 1.25 the seccomp annotations are ignored, and the profile must be set
+        in the seccompProfile field of the pod or container security context instead.
+
+        Security Standard References:
+            - CIS Kubernetes Benchmark v1.6.0 - 5.7.2
+
+        When pod creation "succeeds" with "seccompProfile" set to "RuntimeDefault" in the pod spec
+        Then pod creation "fails or is mutated to compliant" with "seccompProfile" set to "not have a value provided" in the pod spec
 
     @k-pod-012
+    @cis-5.2.7
+    @severity-medium
     Scenario Outline: Ensure that containers cannot deploy with the NET_RAW ability
 
         With Docker as the container runtime the NET_RAW capability is enabled,
@@ -188,12 +228,14 @@ Feature: Pod Security
         Then pod creation "<RESULT>" with "capabilities" set to "<VALUE>" in the pod spec
 
         Examples:
-            | RESULT   | VALUE                     |
-            | fails    | not have a value provided |
-            | fails    | add NET_RAW               |
-            | succeeds | drop NET_RAW              |
+            | RESULT                           | VALUE                     |
+            | fails or is mutated to compliant | not have a value provided |
+            | fails or is mutated to compliant | add NET_RAW               |
+            | succeeds                         | drop NET_RAW              |
 
     @k-pod-013
+    @cis-5.2.7
+    @severity-low
     Scenario: Ensure that containers are not permitted to use ping
 
         If the linux capability for NET_RAW has been properly dropped,
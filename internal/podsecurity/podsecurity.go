@@ -2,19 +2,18 @@ package podsecurity
 
 import (
 	"fmt"
-	"log"
-	"strconv"
+	"regexp"
 	"strings"
 
 	"github.com/cucumber/godog"
 
-	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
 	"github.com/probr/probr-pack-kubernetes/internal/connection"
-	"github.com/probr/probr-pack-kubernetes/internal/summary"
-	audit "github.com/probr/probr-sdk/audit"
-	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-pack-kubernetes/internal/features"
+	"github.com/probr/probr-pack-kubernetes/internal/pods"
+	"github.com/probr/probr-pack-kubernetes/internal/steps"
+	"github.com/probr/probr-pack-kubernetes/internal/toolkit"
 	"github.com/probr/probr-sdk/providers/kubernetes/constructors"
-	"github.com/probr/probr-sdk/providers/kubernetes/errors"
 	"github.com/probr/probr-sdk/utils"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -23,142 +22,79 @@ import (
 type probeStruct struct {
 }
 
-// scenarioState holds the steps and state for any scenario in this probe
+// scenarioState provides the step definitions for this probe, using the state shared by all probes
 type scenarioState struct {
-	name        string
-	currentStep string
-	namespace   string
-	probeAudit  *audit.Probe
-	audit       *audit.Scenario
-	pods        []string
+	*steps.ScenarioState
 }
 
 // Probe meets the service pack interface for adding the logic from this file
 var Probe probeStruct
-var scenario scenarioState
-
-func (scenario *scenarioState) createPodfromObject(podObject *apiv1.Pod) (createdPodObject *apiv1.Pod, err error) {
-	createdPodObject, err = connection.State.CreatePodFromObject(podObject, Probe.Name())
-	if createdPodObject != nil && createdPodObject.ObjectMeta.Name != "" {
-		scenario.pods = append(scenario.pods, createdPodObject.ObjectMeta.Name)
-	}
-	return
-}
-
-func (scenario *scenarioState) aKubernetesClusterIsDeployed() error {
-	// Standard auditing logic to ensures panics are also audited
-	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
-
-	stepTrace.WriteString("Validate that a cluster can be reached using the specified kube config and context; ")
-
-	payload = struct {
-		KubeConfigPath string
-		KubeContext    string
-	}{
-		config.Vars.ServicePacks.Kubernetes.KubeConfigPath,
-		config.Vars.ServicePacks.Kubernetes.KubeContext,
-	}
-
-	err = connection.State.ClusterIsDeployed() // Must be assigned to 'err' be audited
-	return err
-}
-
-func (scenario *scenarioState) toDo(todo string) error {
-	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
-
-	stepTrace.WriteString("This step was included to inform developers that a scenario is incomplete; ")
-	payload = struct {
-		TODO string
-	}{TODO: todo}
-	return godog.ErrPending
-}
+var scenario = scenarioState{&steps.Scenario}
 
 // Attempt to deploy a pod from a default pod spec, with specified modification
 func (scenario *scenarioState) podCreationResultsWithXSetToYInThePodSpec(result, key, value string) (err error) {
 	// Supported key/values:
-	// | Key                        | Value                                                     |
-	// | 'allowPrivilegeEscalation' | 'true', 'false', 'not have a value provided'              |
-	// | 'hostPID'                  | 'true', 'false', 'not have a value provided'              |
-	// | 'hostIPC'                  | 'true', 'false', 'not have a value provided'              |
-	// | 'hostNetwork'              | 'true', 'false', 'not have a value provided'              |
-	// | 'user'                     | Any whole number (such as '0' or '1000')                  |
-	// | 'annotations'              | 'include seccomp profile', 'not include seccomp profile'  |
+	// | Key                        | Value                                                       |
+	// | 'allowPrivilegeEscalation' | 'true', 'false', 'not have a value provided'                |
+	// | 'hostPID'                  | 'true', 'false', 'not have a value provided'                |
+	// | 'hostIPC'                  | 'true', 'false', 'not have a value provided'                |
+	// | 'hostNetwork'              | 'true', 'false', 'not have a value provided'                |
+	// | 'user'                     | Any whole number (such as '0' or '1000')                    |
+	// | 'annotations'              | 'include seccomp profile', 'not include seccomp profile'    |
+	// | 'seccompProfile'           | 'RuntimeDefault', 'Unconfined', 'not have a value provided' |
+	//
+	// Supported results: 'succeeds', 'fails', 'fails or is mutated to compliant'
 
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
-	podShouldCreate, err := shouldPodCreate(result)
+	podShouldCreate, mutationAllowed, err := shouldPodCreate(result)
 	if err != nil {
-		return
+		return err
 	}
 
 	stepTrace.WriteString("Build a pod spec with default values; ")
-	pod := constructors.PodSpec(Probe.Name(), config.Vars.ServicePacks.Kubernetes.ProbeNamespace, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
-
-	// Any key that expects a non-bool value should have it's own case here to handle the pod modification
-
-	switch key {
-	case "user":
-		err = userPodSpecModifier(pod, value)
-	case "annotations":
-		err = annotationsPodSpecModifier(pod, value)
-	case "capabilities":
-		err = capabilitiesPodSpecModifier(pod, value)
-	default:
-		if value == "true" || value == "false" {
-			err = boolPodSpecModifier(pod, key, value)
-		} else if value != "not have a value provided" {
-			err = utils.ReformatError("Expected 'true', 'false', or 'not have a value provided', but found '%s'", value)
-		}
-	}
+	pod, err := steps.BuildPod(Probe.Name(), key, value)
 	if err != nil {
-		return
+		return err
 	}
 
 	stepTrace.WriteString("Create pod from spec; ")
-	createdPod, creationErr := scenario.createPodfromObject(pod)
+	createdPod, creationErr := scenario.CreatePodFromObject(pod)
 
 	stepTrace.WriteString(fmt.Sprintf("Validate pod creation %s; ", result))
-	switch podShouldCreate {
-	case true:
+	var denialReason *admission.DenialReason
+	accepted := true
+	mutations := admission.Mutations(pod, createdPod)
+	switch {
+	case podShouldCreate:
 		if creationErr != nil {
 			err = utils.ReformatError("Pod creation did not succeed: %v", creationErr)
 		}
-	case false:
-		if creationErr == nil {
-			err = utils.ReformatError("Pod creation succeeded, but should have failed")
-		} else {
-			if !errors.IsStatusCode(403, creationErr) && !strings.Contains(creationErr.Error(), "ErrImagePull") {
-				err = utils.ReformatError("Unexpected error during Pod creation : %v", creationErr)
-			}
-		}
+	case creationErr != nil:
+		denialReason, accepted = scenario.ValidateDenial(creationErr, &stepTrace)
+	case mutationAllowed:
+		err = validateMutation(key, mutations, &stepTrace)
+	default:
+		err = utils.ReformatError("Pod creation succeeded, but should have failed")
 	}
 
 	payload = struct {
 		RequestedPod  *apiv1.Pod
 		CreatedPod    *apiv1.Pod
 		CreationError error
+		DenialReason  *admission.DenialReason
+		Mutations     []admission.Mutation
 	}{
 		RequestedPod:  pod,
 		CreatedPod:    createdPod,
 		CreationError: creationErr,
+		DenialReason:  denialReason,
+		Mutations:     mutations,
+	}
+
+	if !accepted {
+		err = steps.Inconclusive("Pod creation was denied by %s, which is not one of the AdmissionControllers", denialReason)
 	}
 	return
 }
@@ -175,50 +111,20 @@ func (scenario *scenarioState) theExecutionOfAXCommandInsideThePodIsY(cmdType, r
 	//     'prevented'
 
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
 	// Guard clause
-	if len(scenario.pods) == 0 {
+	pod, found := scenario.Pods.First()
+	if !found {
 		err = utils.ReformatError("Pod failed to create in the previous step")
 		return err
 	}
-	var expectedExitCodes []int
-	var expectedFailureCodes []int
-
-	var cmd string
-	switch cmdType {
-	case "non-privileged":
-		cmd = "ls"
-	case "privileged":
-		cmd = "mount /fake /fake"
-		expectedFailureCodes = []int{1, 32}
-	case "root":
-		cmd = "touch /dev/probr"
-		expectedFailureCodes = []int{1}
-	case "ping":
-		cmd = "ping -w 4 google.com"
-		expectedFailureCodes = []int{1, 2}
-	default:
-		err = utils.ReformatError("Unexpected value provided for command type: %s", cmdType) // No payload is necessary if an invalid value was provided
-		return err
-	}
-
-	switch result {
-	case "successful":
-		expectedExitCodes = []int{0}
-	case "prevented":
-		expectedExitCodes = expectedFailureCodes
-	default:
-		err = utils.ReformatError("Unexpected value provided for expected command result: %s", result) // No payload is necessary if an invalid value was provided
-		return err
+	cmd, expectedExitCodes, err := steps.CommandForType(cmdType, result)
+	if err != nil {
+		return err // No payload is necessary if an invalid value was provided
 	}
 	stepTrace.WriteString("Attempt to run a command in the pod that was created by the previous step; ")
-	exitCode, stdout, stderr, err := connection.State.ExecCommand(cmd, scenario.namespace, scenario.pods[0])
+	exitCode, stdout, stderr, err := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
 
 	payload = struct {
 		Command           string
@@ -227,6 +133,7 @@ func (scenario *scenarioState) theExecutionOfAXCommandInsideThePodIsY(cmdType, r
 		ExecErr           error
 		ExitCode          int
 		ExpectedExitCodes []int
+		ToolkitImage      string `json:",omitempty"`
 	}{
 		Command:           cmd,
 		StdOut:            stdout,
@@ -234,20 +141,10 @@ func (scenario *scenarioState) theExecutionOfAXCommandInsideThePodIsY(cmdType, r
 		ExecErr:           err,
 		ExitCode:          exitCode,
 		ExpectedExitCodes: expectedExitCodes,
+		ToolkitImage:      toolkit.Image(),
 	}
 
-	var exitKnown bool
-	for _, expectedCode := range expectedExitCodes {
-		if exitCode == expectedCode {
-			exitKnown = true
-			err = nil
-		}
-	}
-	if !exitKnown && exitCode == 0 {
-		err = utils.ReformatError("'%s' succeeded with exit code %d, but shouldn't have.", cmd, exitCode)
-	} else if !exitKnown {
-		err = utils.ReformatError("Unexpected exit code: %d. Please review audit output for more information.", exitCode)
-	}
+	err = steps.ValidateExitCode(cmd, exitCode, expectedExitCodes, err) // Must be assigned to 'err' to be audited
 	return err
 }
 
@@ -255,78 +152,133 @@ func (scenario *scenarioState) aXInspectionShouldOnlyShowTheContainerProcesses(i
 	// Supported inspection types:
 	//     'process'
 	//     'namespace'
+	//
+	// Both inspections read /proc directly rather than parsing the output of distro-specific tools such as ps or lsns
 
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
-	var command string
+	var namespaceType string
 	switch inspectionType {
 	case "process":
-		command = "ps"
+		namespaceType = "pid"
 	case "namespace":
-		command = "lsns -n"
+		namespaceType = "ipc"
 	default:
 		err = utils.ReformatError("Unsupported value provided for inspection type")
 		return
 	}
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return
+	}
 	entrypoint := strings.Join(constructors.DefaultEntrypoint(), " ")
-	exitCode, stdout, _, err := connection.State.ExecCommand(command, scenario.namespace, scenario.pods[0])
+	inspection := procInspection{
+		Entrypoint:       entrypoint,
+		NamespaceType:    namespaceType,
+		HostNamespaceIno: hostNamespaceInodes[namespaceType],
+		ToolkitImage:     toolkit.Image(),
+		Results:          make(map[string]string),
+	}
+	payload = &inspection
 
+	stepTrace.WriteString(fmt.Sprintf("Read the %s namespace of the container from /proc/self/ns; ", namespaceType))
+	stdout, err := inspection.exec(fmt.Sprintf("readlink /proc/self/ns/%s", namespaceType), pod)
 	if err != nil {
-		// TODO: Validate that this fails as expected
-		switch command {
-		case "ps":
-			stepTrace.WriteString("Validate that the container's entrypoint is PID 1 in the process tree; ")
-			// NOTE: This particular expectation depends on using DefaultPodSecurityContext during the previous step
-			//       Also, this explicitly assumes that we're using the alpine distro 'ps' (output is different for ubuntu, for example)
-			expected := fmt.Sprintf("1 1000      0:00 %s", entrypoint)
-			if !strings.Contains(stdout, expected) {
-				err = utils.ReformatError("An entrypoint different from the container's was found for PID 1, suggesting hostPID was used")
-			}
-		case "lsns -n":
-			stepTrace.WriteString("Validate that no namespace has an entrypoint different from the container's entrypoint; ")
-			stdoutLines := strings.Split(stdout, "\n")
-			for _, entry := range stdoutLines {
-				if entry != "" && !strings.Contains(entry, entrypoint) {
-					err = utils.ReformatError("A namespace is visible that uses a different entrypoint from the container, suggesting that hostIPC was used")
-				}
-			}
-		}
+		return
+	}
+	inspection.NamespaceIno = namespaceInode(stdout)
+	if inspection.NamespaceIno == "" {
+		err = utils.ReformatError("Unable to read the %s namespace inode from '%s'", namespaceType, strings.TrimSpace(stdout))
+		return
 	}
 
-	payload = struct {
-		Command    string
-		ExitCode   int
-		Stdout     string
-		Entrypoint string
-	}{
-		Command:    command,
-		ExitCode:   exitCode,
-		Stdout:     stdout,
-		Entrypoint: entrypoint,
+	stepTrace.WriteString("Validate that the namespace is not the host's initial namespace; ")
+	if inspection.NamespaceIno == inspection.HostNamespaceIno {
+		err = utils.ReformatError("The container shares the host's initial %s namespace (inode %s), suggesting that host%s was used",
+			namespaceType, inspection.NamespaceIno, strings.ToUpper(namespaceType))
+		return
+	}
+
+	if inspectionType == "namespace" {
+		// Under nested runtimes such as kind, the node's own namespaces are not the initial namespaces,
+		// so a different inode does not show that the container has its own IPC namespace
+		stepTrace.WriteString("The namespace is not the initial namespace, but may still be shared with a node that is itself nested; ")
+		err = steps.Inconclusive("The container's %s namespace (inode %s) is not the host's initial namespace, but it cannot be shown that it is not shared with the node",
+			namespaceType, inspection.NamespaceIno)
+		return
+	}
+
+	// The entrypoint is only PID 1 in the container's own PID namespace, whether or not the node is nested
+	stepTrace.WriteString("Read the command line of PID 1; ")
+	stdout, err = inspection.exec("cat /proc/1/cmdline", pod)
+	if err != nil {
+		return
+	}
+	inspection.PID1Cmdline = strings.TrimSpace(strings.ReplaceAll(stdout, "\x00", " "))
+
+	stepTrace.WriteString("Validate that the container's entrypoint is PID 1; ")
+	if inspection.PID1Cmdline != entrypoint {
+		err = utils.ReformatError("PID 1 is '%s' rather than the container's entrypoint, suggesting hostPID was used", inspection.PID1Cmdline)
 	}
 	return
 }
 
+// hostNamespaceInodes are the fixed inode numbers the kernel assigns to the initial namespaces.
+// A container in one of these namespaces shares it with the host, but nodes running under a nested runtime
+// do not use the initial namespaces themselves.
+var hostNamespaceInodes = map[string]string{
+	"ipc": "4026531839",
+	"pid": "4026531836",
+}
+
+// namespaceInodePattern extracts the inode from a namespace link, such as 'ipc:[4026531839]'
+var namespaceInodePattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// procInspection records the raw results of each command used to inspect /proc, for the audit payload
+type procInspection struct {
+	Entrypoint       string
+	NamespaceType    string
+	NamespaceIno     string
+	HostNamespaceIno string
+	ToolkitImage     string `json:",omitempty"`
+	PID1Cmdline      string `json:",omitempty"`
+	Results          map[string]string
+}
+
+// exec runs a command in the pod, recording its output. A non-zero exit code is returned as an error.
+func (inspection *procInspection) exec(command string, pod pods.Pod) (stdout string, err error) {
+	exitCode, stdout, stderr, err := connection.State.ExecCommand(toolkit.Command(command), pod.Namespace, pod.Name)
+	inspection.Results[command] = stdout + stderr
+	if err != nil || exitCode != 0 {
+		err = utils.ReformatError("'%s' could not be executed in the container (exit code %d): %v", command, exitCode, err)
+	}
+	return
+}
+
+func namespaceInode(link string) string {
+	match := namespaceInodePattern.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
 func (scenario *scenarioState) thePodIPAndHostIPHaveDifferentValues() (err error) {
 	stepTrace, payload, err := utils.AuditPlaceholders()
-	defer func() {
-		if panicErr := recover(); panicErr != nil {
-			err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
-		}
-		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
-	}()
+	defer scenario.AuditStep(&stepTrace, &payload, &err)
 
+	pod, found := scenario.Pods.First()
+	if !found {
+		err = utils.ReformatError("Pod failed to create in the previous step")
+		return
+	}
 	stepTrace.WriteString("Retrieve IP values from created pod; ")
-	podIP, hostIP, err := connection.State.GetPodIPs(config.Vars.ServicePacks.Kubernetes.ProbeNamespace, scenario.pods[0])
+	podIP, hostIP, err := connection.State.GetPodIPs(pod.Namespace, pod.Name)
 
 	stepTrace.WriteString("Validate that PodIP and HostIP have different values; ")
-	if err != nil && podIP == hostIP {
+	if err == nil && podIP == hostIP {
 		err = utils.ReformatError("Pod IP and Host IP are identical, but should not be")
 	}
 
@@ -335,7 +287,7 @@ func (scenario *scenarioState) thePodIPAndHostIPHaveDifferentValues() (err error
 		PodIP   string
 		HostIP  string
 	}{
-		PodName: scenario.pods[0],
+		PodName: pod.Name,
 		PodIP:   podIP,
 		HostIP:  hostIP,
 	}
@@ -349,7 +301,7 @@ func (probe probeStruct) Name() string {
 
 // Path presents the path of these feature files for external reference
 func (probe probeStruct) Path() string {
-	return probeengine.GetFeaturePath("internal", probe.Name())
+	return features.Path(probe.Name())
 }
 
 // ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
@@ -364,127 +316,53 @@ func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
 
 // ScenarioInitialize initializes the specific test steps
 func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
-
-	ctx.BeforeScenario(func(s *godog.Scenario) {
-		beforeScenario(&scenario, probe.Name(), s)
-	})
-
-	// Background
-	ctx.Step(`^a Kubernetes cluster exists which we can deploy into$`, scenario.aKubernetesClusterIsDeployed)
-
-	// Use for steps that have yet to be written
-	ctx.Step(`^TODO: "([^"]*)"$`, scenario.toDo)
-
-	// Parameterized Scenarios
-	ctx.Step(`^pod creation "([^"]*)" with "([^"]*)" set to "([^"]*)" in the pod spec$`, scenario.podCreationResultsWithXSetToYInThePodSpec)
-	ctx.Step(`^pod creation "([^"]*)" with "([^"]*)" set to "([^"]*)" in the pod spec$`, scenario.podCreationResultsWithXSetToYInThePodSpec)
-	ctx.Step(`^the execution of a "([^"]*)" command inside the pod is "([^"]*)"$`, scenario.theExecutionOfAXCommandInsideThePodIsY)
-	ctx.Step(`^a "([^"]*)" inspection should only show the container processes$`, scenario.aXInspectionShouldOnlyShowTheContainerProcesses)
-	ctx.Step(`^the PodIP and HostIP have different values$`, scenario.thePodIPAndHostIPHaveDifferentValues)
-
-	ctx.AfterScenario(func(s *godog.Scenario, err error) {
-		afterScenario(scenario, probe, s, err)
-	})
-
-	ctx.BeforeStep(func(st *godog.Step) {
-		scenario.currentStep = st.Text
-	})
-
-	ctx.AfterStep(func(st *godog.Step, err error) {
-		scenario.currentStep = ""
-	})
-}
-
-func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
-	s.name = gs.Name
-	s.probeAudit = summary.State.GetProbeLog(probeName)
-	s.audit = summary.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
-	s.pods = make([]string, 0)
-	s.namespace = config.Vars.ServicePacks.Kubernetes.ProbeNamespace
-	probeengine.LogScenarioStart(gs)
-}
-
-func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) { // TODO: err is overwritten before first use
-	if config.Vars.ServicePacks.Kubernetes.KeepPods == "false" {
-		for _, podName := range scenario.pods {
-			err = connection.State.DeletePodIfExists(podName, scenario.namespace, probe.Name())
-			if err != nil {
-				log.Printf("[ERROR] Could not retrieve pod from namespace '%s' for deletion: %s", scenario.namespace, err)
-			}
-		}
-	}
-	probeengine.LogScenarioEnd(gs)
+	steps.Initialize(ctx, probe.Name())
 }
 
-func boolPodSpecModifier(pod *apiv1.Pod, key, value string) (err error) {
-	// Supported keys:
-	//     'allowPrivilegeEscalation'
-	//     'hostPID'
-	//     'hostIPC'
-	//     'hostNetwork'
-	// Supported values:
-	//     'true'
-	//     'false'
-
-	boolValue, _ := strconv.ParseBool(value)
-	switch key {
-	case "allowPrivilegeEscalation":
-		pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = &boolValue
-	case "hostPID":
-		pod.Spec.HostPID = boolValue
-	case "hostIPC":
-		pod.Spec.HostIPC = boolValue
-	case "hostNetwork":
-		pod.Spec.HostNetwork = boolValue
-	default:
-		err = utils.ReformatError("Unsupported key provided: %s", key) // No payload is necessary if an invalid key was provided
-	}
-	return
+func init() {
+	steps.Register(func(ctx *godog.ScenarioContext) {
+		// Parameterized Scenarios
+		ctx.Step(`^pod creation "([^"]*)" with "([^"]*)" set to "([^"]*)" in the pod spec$`, scenario.podCreationResultsWithXSetToYInThePodSpec)
+		ctx.Step(`^the execution of a "([^"]*)" command inside the pod is "([^"]*)"$`, scenario.theExecutionOfAXCommandInsideThePodIsY)
+		ctx.Step(`^a "([^"]*)" inspection should only show the container processes$`, scenario.aXInspectionShouldOnlyShowTheContainerProcesses)
+		ctx.Step(`^the PodIP and HostIP have different values$`, scenario.thePodIPAndHostIPHaveDifferentValues)
+	})
 }
 
-func annotationsPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
-	switch value {
-	case "include seccomp profile":
-		return // default
-	case "not include seccomp profile":
-		pod.ObjectMeta.Annotations = nil
-	default:
-		err = utils.ReformatError("Expected 'include seccomp profile' or 'not include seccomp profile', but found '%s'", value) // No payload is necessary if an invalid value was provided
-	}
-	return
+// mutatedFields maps each pod spec key to the part of the field path that admission must mutate for the pod to be compliant
+var mutatedFields = map[string]string{
+	"allowPrivilegeEscalation": ".allowPrivilegeEscalation",
+	"hostPID":                  "spec.hostPID",
+	"hostIPC":                  "spec.hostIPC",
+	"hostNetwork":              "spec.hostNetwork",
+	"user":                     ".runAsUser",
+	"annotations":              "seccomp",
+	"seccompProfile":           "seccomp",
+	"capabilities":             ".capabilities.",
 }
 
-func capabilitiesPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
-	switch value {
-	case "drop NET_RAW":
-		// default probe pod does this already
-	case "add NET_RAW":
-		pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = []apiv1.Capability{} // clear default cap drop
-		pod.Spec.Containers[0].SecurityContext.Capabilities.Add = append(pod.Spec.Containers[0].SecurityContext.Capabilities.Add, "NET_RAW")
-	case "not have a value provided":
-		pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = []apiv1.Capability{}
-	default:
-		err = utils.ReformatError("Expected 'include NET_RAW' or 'not include NET_RAW', but found '%s'", value) // No payload is necessary if an invalid value was provided
+// validateMutation returns nil if the field set by the key was changed when the pod was admitted
+func validateMutation(key string, mutations []admission.Mutation, stepTrace *strings.Builder) error {
+	matched := admission.MutatedFields(mutations, mutatedFields[key])
+	if mutatedFields[key] == "" || len(matched) == 0 {
+		return utils.ReformatError("Pod creation succeeded without '%s' being mutated, but should have failed", key)
 	}
-	return
-}
-
-func userPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
-	intValue, err := strconv.ParseInt(value, 10, 64)
-	if err != nil {
-		err = utils.ReformatError("Expected value to be a whole number, but found '%s' (%s)", value, err) // No payload is necessary if an invalid value was provided
-		return err
+	for _, mutation := range matched {
+		stepTrace.WriteString(fmt.Sprintf("Admission mutated %s; ", mutation))
 	}
-	pod.Spec.SecurityContext.RunAsUser = &intValue
-	return
+	return nil
 }
 
-func shouldPodCreate(result string) (shouldCreate bool, err error) {
+// shouldPodCreate returns whether the pod should be created, and whether a pod that should not be created
+// may instead be admitted after a mutating admission control has changed the offending field
+func shouldPodCreate(result string) (shouldCreate, mutationAllowed bool, err error) {
 	switch result {
 	case "succeeds":
 		shouldCreate = true
 	case "fails":
 		shouldCreate = false
+	case "fails or is mutated to compliant":
+		mutationAllowed = true
 	default:
 		err = utils.ReformatError("Unexpected value provided for expected pod creation result: %s", result) // No payload is necessary if an invalid value was provided
 	}
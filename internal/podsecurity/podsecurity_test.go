@@ -0,0 +1,188 @@
+package podsecurity
+
+import (
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/connection/fake"
+	"github.com/probr/probr-pack-kubernetes/internal/probetest"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func hostPID(pod *apiv1.Pod) bool {
+	return pod.Spec.HostPID
+}
+
+func hostNamespaces(pod *apiv1.Pod) bool {
+	return pod.Spec.HostPID || pod.Spec.HostIPC || pod.Spec.HostNetwork
+}
+
+func privilegeEscalation(pod *apiv1.Pod) bool {
+	sc := pod.Spec.Containers[0].SecurityContext
+	return sc != nil && sc.AllowPrivilegeEscalation != nil && *sc.AllowPrivilegeEscalation
+}
+
+func runAsRoot(pod *apiv1.Pod) bool {
+	sc := pod.Spec.SecurityContext
+	return sc != nil && sc.RunAsUser != nil && *sc.RunAsUser == 0
+}
+
+func noSeccompProfile(pod *apiv1.Pod) bool {
+	return len(pod.ObjectMeta.Annotations) == 0 && (pod.Spec.SecurityContext == nil || pod.Spec.SecurityContext.SeccompProfile == nil)
+}
+
+func netRawNotDropped(pod *apiv1.Pod) bool {
+	sc := pod.Spec.Containers[0].SecurityContext
+	if sc == nil || sc.Capabilities == nil {
+		return true
+	}
+	for _, capability := range sc.Capabilities.Drop {
+		if capability == "NET_RAW" || capability == "ALL" {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScenarios(t *testing.T) {
+	var tests = []struct {
+		name     string
+		setup    func(cluster *fake.Cluster)
+		expected map[string][]string
+	}{
+		{
+			name: "every pod is admitted and every command succeeds",
+			expected: map[string][]string{
+				"@k-pod-001": {"Failed"},
+				"@k-pod-002": {"Failed", "Failed"},
+				"@k-pod-003": {"Failed"},
+				"@k-pod-004": {"Failed", "Failed"},
+				"@k-pod-005": {"Failed"},
+				"@k-pod-006": {"Failed", "Failed"},
+				"@k-pod-007": {"Failed"},
+				"@k-pod-008": {"Passed", "Passed"},
+				"@k-pod-009": {"Failed"},
+				"@k-pod-010": {"Failed"},
+				"@k-pod-011": {"Failed", "Failed"},
+				"@k-pod-012": {"Failed", "Failed", "Passed"},
+				"@k-pod-013": {"Failed"},
+			},
+		},
+		{
+			name: "privilege escalation is denied",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "restricted:latest": allowPrivilegeEscalation != false (container "probr" must set securityContext.allowPrivilegeEscalation=false)`, privilegeEscalation)
+			},
+			expected: map[string][]string{"@k-pod-001": {"Passed"}},
+		},
+		{
+			name: "privileged commands are prevented",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`mount /fake /fake`, fake.ExecResult{ExitCode: 32, Stderr: "mount: permission denied (are you root?)"})
+				cluster.OnExec(`touch /dev/probr`, fake.ExecResult{ExitCode: 1, Stderr: "touch: /dev/probr: Permission denied"})
+				cluster.OnExec(`ping -w 4`, fake.ExecResult{ExitCode: 1, Stderr: "ping: permission denied (are you root?)"})
+			},
+			expected: map[string][]string{
+				"@k-pod-002": {"Passed", "Passed"},
+				"@k-pod-010": {"Passed"},
+				"@k-pod-013": {"Passed"},
+			},
+		},
+		{
+			name: "host namespaces are denied",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "baseline:latest": host namespaces (hostNetwork=true, hostPID=true, hostIPC=true)`, hostNamespaces)
+			},
+			expected: map[string][]string{
+				"@k-pod-003": {"Passed"},
+				"@k-pod-005": {"Passed"},
+				"@k-pod-007": {"Passed"},
+			},
+		},
+		{
+			name:     "host PID is mutated to compliant",
+			setup:    func(cluster *fake.Cluster) { cluster.Mutate(func(pod *apiv1.Pod) { pod.Spec.HostPID = false }) },
+			expected: map[string][]string{"@k-pod-003": {"Passed"}},
+		},
+		{
+			name: "host PID is denied by quota",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`pods "probe" is forbidden: exceeded quota: pod-count, requested: pods=1, used: pods=1, limited: pods=1`, hostPID)
+			},
+			expected: map[string][]string{"@k-pod-003": {"Inconclusive"}},
+		},
+		{
+			name: "compliant pod is denied",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "restricted:latest": runAsNonRoot != true`, func(*apiv1.Pod) bool { return true })
+			},
+			expected: map[string][]string{"@k-pod-003": {"Failed"}},
+		},
+		{
+			name: "process inspection shows the container processes",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`readlink /proc/self/ns/pid`, fake.ExecResult{Stdout: "pid:[4026532301]\n"})
+				cluster.OnExec(`cat /proc/1/cmdline`, fake.ExecResult{Stdout: "sleep\x003600\x00"})
+			},
+			expected: map[string][]string{"@k-pod-004": {"Passed"}},
+		},
+		{
+			// Under a nested runtime the node's PID namespace is not the initial namespace, but PID 1 is still the node's init
+			name: "process inspection shows nested node processes",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`readlink /proc/self/ns/pid`, fake.ExecResult{Stdout: "pid:[4026532301]\n"})
+				cluster.OnExec(`cat /proc/1/cmdline`, fake.ExecResult{Stdout: "/sbin/init\x00"})
+			},
+			expected: map[string][]string{"@k-pod-004": {"Failed"}},
+		},
+		{
+			name: "namespace inspection in the initial namespace",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`readlink /proc/self/ns/ipc`, fake.ExecResult{Stdout: "ipc:[4026531839]\n"})
+			},
+			expected: map[string][]string{"@k-pod-006": {"Failed"}},
+		},
+		{
+			name: "namespace inspection in another namespace",
+			setup: func(cluster *fake.Cluster) {
+				cluster.OnExec(`readlink /proc/self/ns/ipc`, fake.ExecResult{Stdout: "ipc:[4026532402]\n"})
+			},
+			expected: map[string][]string{"@k-pod-006": {"Inconclusive"}},
+		},
+		{
+			name:     "pods are mutated to use the host network",
+			setup:    func(cluster *fake.Cluster) { cluster.Mutate(func(pod *apiv1.Pod) { pod.Spec.HostNetwork = true }) },
+			expected: map[string][]string{"@k-pod-008": {"Failed", "Failed"}},
+		},
+		{
+			name: "root user is denied",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "restricted:latest": runAsUser=0 (pod must not set runAsUser=0)`, runAsRoot)
+			},
+			expected: map[string][]string{"@k-pod-009": {"Passed"}},
+		},
+		{
+			name: "seccomp profile is required",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "restricted:latest": seccompProfile (pod must set securityContext.seccompProfile.type)`, noSeccompProfile)
+			},
+			expected: map[string][]string{"@k-pod-011": {"Passed", "Passed"}},
+		},
+		{
+			name: "NET_RAW must be dropped",
+			setup: func(cluster *fake.Cluster) {
+				cluster.Deny(`violates PodSecurity "restricted:latest": unrestricted capabilities (container "probr" must set securityContext.capabilities.drop=["ALL"])`, netRawNotDropped)
+			},
+			expected: map[string][]string{"@k-pod-012": {"Passed", "Passed", "Passed"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := fake.NewCluster()
+			if test.setup != nil {
+				test.setup(cluster)
+			}
+			audit := probetest.RunAll(t, Probe, cluster)
+			probetest.ExpectResults(t, audit, test.expected)
+		})
+	}
+}
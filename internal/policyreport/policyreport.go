@@ -0,0 +1,217 @@
+// Package policyreport publishes scenario results as wgpolicyk8s.io PolicyReport and ClusterPolicyReport objects,
+// so that they can be consumed by other cluster tools such as Policy Reporter
+package policyreport
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/probr/probr-pack-kubernetes/internal/severity"
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Name is used for both the PolicyReport and the ClusterPolicyReport
+const Name = "probr-kubernetes"
+
+// ClusterScopeTag marks scenarios whose findings apply to the whole cluster rather than the probe namespace
+const ClusterScopeTag = "@cluster-scope"
+
+// cisTagPrefix is used by feature files to reference the CIS benchmark control covered by a scenario
+const cisTagPrefix = "@cis-"
+
+const apiVersion = "wgpolicyk8s.io/v1alpha2"
+
+// policyTag matches the scenario ID tags such as '@k-gen-001', but not the probe tags such as '@k-gen'
+var policyTag = regexp.MustCompile(`^@k-[a-z]+-\d+$`)
+
+var (
+	// PolicyReportResource identifies the namespaced PolicyReport custom resource
+	PolicyReportResource = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "policyreports"}
+
+	// ClusterPolicyReportResource identifies the cluster-scoped ClusterPolicyReport custom resource
+	ClusterPolicyReportResource = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "clusterpolicyreports"}
+)
+
+// Publish creates or updates the PolicyReport in the provided namespace and the ClusterPolicyReport
+// using the scenario results in the provided summary
+func Publish(client dynamic.Interface, state *audit.SummaryState, namespace string) error {
+	namespaced, cluster := Build(state, namespace, time.Now())
+
+	err := apply(client.Resource(PolicyReportResource).Namespace(namespace), namespaced)
+	if err != nil {
+		return utils.ReformatError("Failed to publish PolicyReport to namespace '%s': %v", namespace, err)
+	}
+	err = apply(client.Resource(ClusterPolicyReportResource), cluster)
+	if err != nil {
+		return utils.ReformatError("Failed to publish ClusterPolicyReport: %v", err)
+	}
+	log.Printf("[INFO] Published PolicyReport '%s/%s' and ClusterPolicyReport '%s'", namespace, Name, Name)
+	return nil
+}
+
+// Build converts the scenario results into a namespaced PolicyReport and a ClusterPolicyReport.
+// Scenarios tagged with ClusterScopeTag are written to the ClusterPolicyReport.
+func Build(state *audit.SummaryState, namespace string, now time.Time) (namespaced, cluster *unstructured.Unstructured) {
+	var namespacedResults, clusterResults []interface{}
+	namespacedSummary, clusterSummary := newSummary(), newSummary()
+
+	for _, probeName := range sortedProbes(state) {
+		probe := state.Probes[probeName]
+		for _, i := range sortedScenarios(probe) {
+			scenario := probe.Scenarios[i]
+			result := scenarioResult(probeName, scenario, now)
+			if hasTag(scenario.Tags, ClusterScopeTag) {
+				clusterResults = append(clusterResults, result)
+				clusterSummary[result["result"].(string)]++
+			} else {
+				namespacedResults = append(namespacedResults, result)
+				namespacedSummary[result["result"].(string)]++
+			}
+		}
+	}
+
+	namespaced = newReport("PolicyReport", namespace, namespacedResults, namespacedSummary)
+	cluster = newReport("ClusterPolicyReport", "", clusterResults, clusterSummary)
+	return
+}
+
+func newReport(kind, namespace string, results []interface{}, summary map[string]int64) *unstructured.Unstructured {
+	metadata := map[string]interface{}{
+		"name": Name,
+		"labels": map[string]interface{}{
+			"app.kubernetes.io/managed-by": "probr",
+		},
+	}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	summaryContent := make(map[string]interface{})
+	for key, value := range summary {
+		summaryContent[key] = value
+	}
+	if results == nil {
+		results = []interface{}{}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   metadata,
+		"summary":    summaryContent,
+		"results":    results,
+	}}
+}
+
+func newSummary() map[string]int64 {
+	return map[string]int64{"pass": 0, "fail": 0, "warn": 0, "error": 0, "skip": 0}
+}
+
+func scenarioResult(probeName string, scenario *audit.Scenario, now time.Time) map[string]interface{} {
+	policy := scenario.Name
+	properties := map[string]interface{}{
+		"probe":    probeName,
+		"scenario": scenario.Name,
+	}
+	for _, tag := range scenario.Tags {
+		if policyTag.MatchString(tag) {
+			policy = strings.TrimPrefix(tag, "@")
+		} else if strings.HasPrefix(tag, cisTagPrefix) {
+			properties["cisID"] = strings.TrimPrefix(tag, cisTagPrefix)
+		}
+	}
+	return map[string]interface{}{
+		"source":     "probr",
+		"policy":     policy,
+		"rule":       scenario.Name,
+		"category":   probeName,
+		"severity":   severity.FromTags(scenario.Tags),
+		"result":     resultValue(scenario.Result),
+		"scored":     true,
+		"message":    stepTrace(scenario),
+		"properties": properties,
+		"timestamp": map[string]interface{}{
+			"seconds": now.Unix(),
+			"nanos":   int64(0),
+		},
+	}
+}
+
+// resultValue maps an audit result to one of the values supported by the PolicyReport schema
+func resultValue(result string) string {
+	switch result {
+	case "Passed":
+		return "pass"
+	case "Failed":
+		return "fail"
+	case "Inconclusive":
+		return "warn"
+	case "Waived", "Given Not Met":
+		return "skip"
+	}
+	return "error"
+}
+
+// stepTrace summarizes the audited steps of a scenario, including any errors
+func stepTrace(scenario *audit.Scenario) string {
+	var trace strings.Builder
+	for i := 1; i <= len(scenario.Steps); i++ {
+		step := scenario.Steps[i]
+		if step == nil {
+			continue
+		}
+		line := strings.TrimSpace(step.Description)
+		if step.Error != "" {
+			line = strings.TrimSpace(fmt.Sprintf("%s Error: %s", line, step.Error))
+		}
+		trace.WriteString(fmt.Sprintf("%d. %s [%s]: %s\n", i, step.Name, step.Result, line))
+	}
+	return strings.TrimSpace(trace.String())
+}
+
+// apply creates the report, or replaces the existing report's content if it already exists
+func apply(client dynamic.ResourceInterface, report *unstructured.Unstructured) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	existing, err := client.Get(ctx, report.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = client.Create(ctx, report, metav1.CreateOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	report.SetResourceVersion(existing.GetResourceVersion())
+	_, err = client.Update(ctx, report, metav1.UpdateOptions{})
+	return err
+}
+
+func hasTag(tags []string, tag string) bool {
+	_, found := utils.FindString(tags, tag)
+	return found
+}
+
+func sortedProbes(state *audit.SummaryState) (names []string) {
+	for name := range state.Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func sortedScenarios(probe *audit.Probe) (keys []int) {
+	for k := range probe.Scenarios {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return
+}
@@ -0,0 +1,186 @@
+package policyreport
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	messages "github.com/cucumber/messages-go/v10"
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+const namespace = "probr"
+
+var now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testState returns a summary with a scenario for each audit result, one of which is cluster-scoped
+func testState() *audit.SummaryState {
+	state := audit.NewSummaryState("kubernetes")
+
+	general := state.GetProbeLog("general")
+	dashboard := general.InitializeAuditor("Ensure the Kubernetes Web UI does not have privileged access",
+		tags("@k-gen", "@k-gen-001", "@cis-6.10.1", "@severity-critical", ClusterScopeTag))
+	dashboard.AuditScenarioStep("a Kubernetes cluster exists", "Check the cluster is deployed; ", nil, nil)
+	dashboard.AuditScenarioStep("the Kubernetes Web UI service account is not bound to a privileged role", "List cluster role bindings; ", nil,
+		utils.ReformatError("Service account 'kubernetes-dashboard' is bound to 'cluster-admin'"))
+	reachable := general.InitializeAuditor("Ensure the Kubernetes Web UI cannot be reached from a pod",
+		tags("@k-gen", "@k-gen-001", "@cis-6.10.1", "@severity-high"))
+	reachable.AuditScenarioStep("a Kubernetes cluster exists", "Check the cluster is deployed; ", nil, nil)
+
+	podsecurity := state.GetProbeLog("podsecurity")
+	waived := podsecurity.InitializeAuditor("Prevent privileged access", tags("@k-pod", "@k-pod-001", "@severity-low"))
+	waived.AuditScenarioStep("a Kubernetes cluster exists", "Check the cluster is deployed; ", nil, nil)
+	waived.AuditScenarioStep("pod creation fails", "Create pod; ", nil, utils.ReformatError("Pod was created"))
+	waived.Result = "Waived"
+	givenNotMet := podsecurity.InitializeAuditor("Prevent host PID", tags("@k-pod", "@k-pod-003"))
+	givenNotMet.AuditScenarioStep("a Kubernetes cluster exists", "Check the cluster is deployed; ", nil, utils.ReformatError("Cluster is not deployed"))
+	return &state
+}
+
+func tags(names ...string) (pickleTags []*messages.Pickle_PickleTag) {
+	for _, name := range names {
+		pickleTags = append(pickleTags, &messages.Pickle_PickleTag{Name: name})
+	}
+	return
+}
+
+func results(t *testing.T, report *unstructured.Unstructured) []map[string]interface{} {
+	list, _, err := unstructured.NestedSlice(report.Object, "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []map[string]interface{}
+	for _, result := range list {
+		found = append(found, result.(map[string]interface{}))
+	}
+	return found
+}
+
+func TestBuild(t *testing.T) {
+	namespaced, cluster := Build(testState(), namespace, now)
+
+	if namespaced.GetKind() != "PolicyReport" || namespaced.GetNamespace() != namespace || namespaced.GetName() != Name {
+		t.Errorf("Unexpected PolicyReport metadata: %s %s/%s", namespaced.GetKind(), namespaced.GetNamespace(), namespaced.GetName())
+	}
+	if cluster.GetKind() != "ClusterPolicyReport" || cluster.GetNamespace() != "" || cluster.GetName() != Name {
+		t.Errorf("Unexpected ClusterPolicyReport metadata: %s %s/%s", cluster.GetKind(), cluster.GetNamespace(), cluster.GetName())
+	}
+
+	expected := map[string]string{
+		"Ensure the Kubernetes Web UI cannot be reached from a pod": "pass",
+		"Prevent privileged access":                                 "skip",
+		"Prevent host PID":                                          "skip",
+	}
+	namespacedResults := results(t, namespaced)
+	if len(namespacedResults) != len(expected) {
+		t.Fatalf("Expected %d namespaced results, found %d", len(expected), len(namespacedResults))
+	}
+	for _, result := range namespacedResults {
+		if want := expected[result["rule"].(string)]; result["result"] != want {
+			t.Errorf("Expected result '%s' for '%s', found '%s'", want, result["rule"], result["result"])
+		}
+	}
+
+	clusterResults := results(t, cluster)
+	if len(clusterResults) != 1 {
+		t.Fatalf("Expected the cluster-scoped scenario in the ClusterPolicyReport, found %d results", len(clusterResults))
+	}
+	dashboard := clusterResults[0]
+	if dashboard["policy"] != "k-gen-001" || dashboard["severity"] != "critical" || dashboard["result"] != "fail" || dashboard["category"] != "general" {
+		t.Errorf("Unexpected cluster-scoped result: %v", dashboard)
+	}
+	if cisID := dashboard["properties"].(map[string]interface{})["cisID"]; cisID != "6.10.1" {
+		t.Errorf("Expected cisID '6.10.1', found '%v'", cisID)
+	}
+	message := dashboard["message"].(string)
+	if !strings.Contains(message, "List cluster role bindings; Error: Service account 'kubernetes-dashboard' is bound to 'cluster-admin'") {
+		t.Errorf("Expected the step error to be separated from the step trace, found: %s", message)
+	}
+
+	summary, _, _ := unstructured.NestedMap(cluster.Object, "summary")
+	if summary["fail"] != int64(1) || summary["pass"] != int64(0) {
+		t.Errorf("Unexpected ClusterPolicyReport summary: %v", summary)
+	}
+	summary, _, _ = unstructured.NestedMap(namespaced.Object, "summary")
+	if summary["pass"] != int64(1) || summary["skip"] != int64(2) || summary["fail"] != int64(0) {
+		t.Errorf("Unexpected PolicyReport summary: %v", summary)
+	}
+}
+
+func TestResultValue(t *testing.T) {
+	for result, expected := range map[string]string{
+		"Passed":        "pass",
+		"Failed":        "fail",
+		"Waived":        "skip",
+		"Given Not Met": "skip",
+		"Inconclusive":  "warn",
+		"":              "error",
+	} {
+		if value := resultValue(result); value != expected {
+			t.Errorf("Expected '%s' for audit result '%s', found '%s'", expected, result, value)
+		}
+	}
+}
+
+func TestPublishCreatesThenUpdates(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	state := testState()
+
+	if err := Publish(client, state, namespace); err != nil {
+		t.Fatalf("First publish failed: %v", err)
+	}
+	if err := Publish(client, state, namespace); err != nil {
+		t.Fatalf("Second publish failed: %v", err)
+	}
+
+	var verbs []string
+	for _, action := range client.Actions() {
+		verbs = append(verbs, action.GetVerb()+" "+action.GetResource().Resource)
+	}
+	expected := []string{
+		"get policyreports", "create policyreports", "get clusterpolicyreports", "create clusterpolicyreports",
+		"get policyreports", "update policyreports", "get clusterpolicyreports", "update clusterpolicyreports",
+	}
+	if strings.Join(verbs, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected actions [%s], found [%s]", strings.Join(expected, ", "), strings.Join(verbs, ", "))
+	}
+
+	report, err := client.Resource(PolicyReportResource).Namespace(namespace).Get(context.Background(), Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("PolicyReport was not published: %v", err)
+	}
+	if len(results(t, report)) != 3 {
+		t.Errorf("Expected 3 results in the published PolicyReport, found %d", len(results(t, report)))
+	}
+	report, err = client.Resource(ClusterPolicyReportResource).Get(context.Background(), Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("ClusterPolicyReport was not published: %v", err)
+	}
+	if len(results(t, report)) != 1 {
+		t.Errorf("Expected 1 result in the published ClusterPolicyReport, found %d", len(results(t, report)))
+	}
+}
+
+func TestScenarioResultPolicyIgnoresTagOrder(t *testing.T) {
+	state := audit.NewSummaryState("kubernetes")
+	general := state.GetProbeLog("general")
+	for _, order := range [][]string{
+		{"@k-gen", "@k-gen-005", "@cis-5.7.4"},
+		{"@cis-5.7.4", "@k-gen-005", "@k-gen"},
+	} {
+		scenario := general.InitializeAuditor("Ensure DNS resolution is restricted", tags(order...))
+		result := scenarioResult("general", scenario, now)
+		if result["policy"] != "k-gen-005" {
+			t.Errorf("Expected policy 'k-gen-005' for tags %v, found '%v'", order, result["policy"])
+		}
+		if cisID := result["properties"].(map[string]interface{})["cisID"]; cisID != "5.7.4" {
+			t.Errorf("Expected cisID '5.7.4' for tags %v, found '%v'", order, cisID)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package probetest runs the feature file of a probe against a fake cluster, so that the steps of each probe
+// can be tested end to end without a live cluster
+package probetest
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/summary"
+	audit "github.com/probr/probr-sdk/audit"
+	sdkConfig "github.com/probr/probr-sdk/config"
+	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-sdk/utils"
+)
+
+const (
+	// AuthorisedImage is used as the AuthorisedContainerImage, unless one is configured by the environment
+	AuthorisedImage = "registry.example.com/probr/busybox"
+
+	// UnauthorisedImage is used as the UnauthorisedContainerImage, unless one is configured by the environment
+	UnauthorisedImage = "docker.io/library/busybox"
+)
+
+var initConfig sync.Once
+
+// scenarioID matches the tag that identifies each scenario in a feature file
+var scenarioID = regexp.MustCompile(`^@k-[a-z]+-\d+$`)
+
+// Run executes the scenarios of the probe that match the tags against the cluster, which is usually a fake.Cluster,
+// and returns the probe's audit. Output is written to a temporary directory that is removed when the test ends.
+func Run(t *testing.T, probe probeengine.Probe, cluster connection.Connection, tags string) *audit.Probe {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-"+probe.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	initConfig.Do(func() {
+		os.Setenv("PROBR_WRITE_DIRECTORY", dir)
+		os.Setenv("PROBR_TMP_DIR", dir)
+		config.Vars.Init()
+		k8s := &config.Vars.ServicePacks.Kubernetes
+		if k8s.AuthorisedContainerImage == "" {
+			k8s.AuthorisedContainerImage = AuthorisedImage
+		}
+		if k8s.UnauthorisedContainerImage == "" {
+			k8s.UnauthorisedContainerImage = UnauthorisedImage
+		}
+	})
+	sdkConfig.GlobalConfig.WriteDirectory = dir
+	sdkConfig.GlobalConfig.TmpDir = dir
+	sdkConfig.GlobalConfig.PrepareOutputDirectory("audit", "cucumber")
+
+	connection.State = cluster
+	connection.Version, _ = cluster.ServerVersion()
+	summary.State = audit.NewSummaryState("kubernetes")
+
+	store := probeengine.NewProbeStore("kubernetes", tags, &summary.State)
+	if _, err := store.RunAllProbes([]probeengine.Probe{probe}); err != nil {
+		t.Logf("Probe '%s' did not succeed: %v", probe.Name(), err)
+	}
+	probeAudit := summary.State.Probes[probe.Name()]
+	if probeAudit == nil || len(probeAudit.Scenarios) == 0 {
+		t.Fatalf("No scenarios of probe '%s' matching '%s' were executed", probe.Name(), tags)
+	}
+	return probeAudit
+}
+
+// RunAll executes every scenario of the probe against the cluster, without filtering by tag, and fails the test
+// unless each scenario in the probe's feature file was audited with a result. Each row of the Examples of a
+// Scenario Outline is counted as a scenario. The feature file is read from the probe's package directory.
+func RunAll(t *testing.T, probe probeengine.Probe, cluster connection.Connection) *audit.Probe {
+	t.Helper()
+	probeAudit := Run(t, probe, cluster, "")
+	expected, err := featureScenarios(probe.Name() + ".feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) == 0 {
+		t.Fatalf("No scenarios found in the feature file of probe '%s'", probe.Name())
+	}
+	for id, count := range expected {
+		results := Results(probeAudit, id)
+		if len(results) != count {
+			t.Errorf("Expected %d scenarios tagged %s to be executed, found %d", count, id, len(results))
+		}
+		for _, result := range results {
+			if result == "" {
+				t.Errorf("A scenario tagged %s was executed without a result", id)
+			}
+		}
+	}
+	return probeAudit
+}
+
+// featureScenarios returns the number of scenarios in the feature file for each scenario ID, such as @k-gen-002
+func featureScenarios(path string) (scenarios map[string]int, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	scenarios = make(map[string]int)
+	var id string
+	var outline, header bool
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case scenarioID.MatchString(line):
+			id = line
+		case strings.HasPrefix(line, "Scenario Outline:"):
+			outline = true
+		case strings.HasPrefix(line, "Scenario:"):
+			outline = false
+			scenarios[id]++ // Version-gated variants of a scenario share its ID
+		case strings.HasPrefix(line, "Examples:"):
+			header = true
+		case outline && strings.HasPrefix(line, "|"):
+			if header {
+				header = false
+				continue
+			}
+			scenarios[id]++
+		}
+	}
+	return
+}
+
+// Results returns the result of each audited scenario with the tag, in the order the scenarios were executed
+func Results(probe *audit.Probe, tag string) (results []string) {
+	for _, scenario := range sorted(probe) {
+		if _, found := utils.FindString(scenario.Tags, tag); found {
+			results = append(results, scenario.Result)
+		}
+	}
+	return
+}
+
+// Expect fails the test unless every scenario with the tag has the expected result.
+// The steps of any unexpected scenario are logged to explain the result.
+func Expect(t *testing.T, probe *audit.Probe, tag, expected string) {
+	t.Helper()
+	results := Results(probe, tag)
+	if len(results) == 0 {
+		t.Errorf("No scenarios tagged %s were executed", tag)
+	}
+	for _, scenario := range sorted(probe) {
+		if _, found := utils.FindString(scenario.Tags, tag); !found || scenario.Result == expected {
+			continue
+		}
+		t.Errorf("Expected scenario '%s' (%s) to be %s, but it was %s", scenario.Name, tag, expected, scenario.Result)
+		for i := 1; i <= len(scenario.Steps); i++ {
+			if step := scenario.Steps[i]; step != nil {
+				t.Logf("  %d. %s [%s]: %s %s", i, step.Name, step.Result, step.Description, step.Error)
+			}
+		}
+	}
+}
+
+// ExpectResults fails the test unless the scenarios with each tag have the expected results, in the order they were
+// executed. A single expected result applies to every scenario with the tag.
+func ExpectResults(t *testing.T, probe *audit.Probe, expected map[string][]string) {
+	t.Helper()
+	var tags []string
+	for tag := range expected {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if len(expected[tag]) == 1 {
+			Expect(t, probe, tag, expected[tag][0])
+			continue
+		}
+		if results := Results(probe, tag); !reflect.DeepEqual(results, expected[tag]) {
+			t.Errorf("Expected the scenarios tagged %s to be %v, but they were %v", tag, expected[tag], results)
+		}
+	}
+}
+
+func sorted(probe *audit.Probe) (scenarios []*audit.Scenario) {
+	var keys []int
+	for key := range probe.Scenarios {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	for _, key := range keys {
+		scenarios = append(scenarios, probe.Scenarios[key])
+	}
+	return
+}
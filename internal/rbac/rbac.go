@@ -0,0 +1,170 @@
+// Package rbac describes the Kubernetes API permissions required by each probe,
+// and generates the manifests needed to grant them to an in-cluster service account
+package rbac
+
+import (
+	"bytes"
+	"sort"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Name is used for the ServiceAccount, ClusterRole and ClusterRoleBinding in the generated manifest
+const Name = "probr-kubernetes"
+
+// Permission describes the verbs used against a single API resource
+type Permission struct {
+	APIGroup string
+	Resource string
+	Verbs    []string
+}
+
+// Connection lists the permissions used while initializing the connection, regardless of which probes are run
+var Connection = []Permission{
+	{"", "namespaces", []string{"create", "get"}}, // GetOrCreateNamespace
+}
+
+// Outputs lists the permissions used by optional output sinks
+var Outputs = []Permission{
+	{"wgpolicyk8s.io", "clusterpolicyreports", []string{"create", "get", "update"}}, // policyreport.Publish
+	{"wgpolicyk8s.io", "policyreports", []string{"create", "get", "update"}},        // policyreport.Publish
+}
+
+// Methods lists the permissions used by each method of connection.Connection when connected to a live cluster.
+// The SDK waits for a pod to be running by watching pods before executing a command in it or reading its IPs.
+var Methods = map[string][]Permission{
+	"ClusterIsDeployed":   nil,
+	"CreatePodFromObject": {{"", "pods", []string{"create"}}},
+	"DeletePodIfExists":   {{"", "pods", []string{"delete"}}},
+	"ExecCommand": {
+		{"", "pods", []string{"watch"}},
+		{"", "pods/exec", []string{"create"}},
+	},
+	"GetPodIPs":          {{"", "pods", []string{"get", "watch"}}},
+	"GetPodsByNamespace": {{"", "namespaces", []string{"get"}}, {"", "pods", []string{"list"}}},
+	"GetAllPods":         {{"", "pods", []string{"list"}}},
+	"GetAllDeployments":  {{"apps", "deployments", []string{"list"}}},
+	"GetAllServices":     {{"", "services", []string{"list"}}},
+	"GetNamespaces":      {{"", "namespaces", []string{"list"}}},
+	"GetClusterRoleBindings": {
+		{"rbac.authorization.k8s.io", "clusterrolebindings", []string{"list"}},
+	},
+	"GetAllRoleBindings": {{"rbac.authorization.k8s.io", "rolebindings", []string{"list"}}},
+	"ServerVersion":      nil, // The version endpoint is readable by all authenticated users
+}
+
+// Steps lists the permissions used by the shared pod steps, which are available to every probe's feature file,
+// including features that override the built-in features
+var Steps = []Permission{
+	{"", "pods", []string{"create", "delete", "get", "watch"}}, // CreatePodFromObject, DeletePodIfExists, GetPodIPs, ExecCommand
+	{"", "pods/exec", []string{"create"}},                      // ExecCommand
+}
+
+// Probes lists the permissions used by the API calls made within each probe's steps, in addition to Steps
+var Probes = map[string][]Permission{
+	"container_registry_access": {
+		{"", "pods", []string{"create", "delete"}}, // CreatePodFromObject, DeletePodIfExists
+	},
+	"general": {
+		{"", "pods", []string{"create", "delete", "list", "watch"}},            // CreatePodFromObject, DeletePodIfExists, GetAllPods, ExecCommand
+		{"", "pods/exec", []string{"create"}},                                  // ExecCommand
+		{"", "services", []string{"list"}},                                     // GetAllServices
+		{"apps", "deployments", []string{"list"}},                              // GetAllDeployments
+		{"rbac.authorization.k8s.io", "clusterrolebindings", []string{"list"}}, // GetClusterRoleBindings
+		{"rbac.authorization.k8s.io", "rolebindings", []string{"list"}},        // GetAllRoleBindings
+	},
+	"pod_security_admission": {
+		{"", "namespaces", []string{"list"}},       // GetNamespaces
+		{"", "pods", []string{"create", "delete"}}, // CreatePodFromObject, DeletePodIfExists
+	},
+	"podsecurity": {
+		{"", "pods", []string{"create", "delete", "get", "watch"}}, // CreatePodFromObject, DeletePodIfExists, GetPodIPs, ExecCommand
+		{"", "pods/exec", []string{"create"}},                      // ExecCommand
+	},
+}
+
+// Rules merges the permissions for the connection, the shared steps and all probes into a sorted list of policy rules
+func Rules() (rules []rbacv1.PolicyRule) {
+	type resource struct{ apiGroup, name string }
+	verbs := make(map[resource]map[string]bool)
+	add := func(permissions []Permission) {
+		for _, p := range permissions {
+			key := resource{p.APIGroup, p.Resource}
+			if verbs[key] == nil {
+				verbs[key] = make(map[string]bool)
+			}
+			for _, verb := range p.Verbs {
+				verbs[key][verb] = true
+			}
+		}
+	}
+	add(Connection)
+	add(Outputs)
+	add(Steps)
+	for _, permissions := range Probes {
+		add(permissions)
+	}
+
+	for key, verbSet := range verbs {
+		rule := rbacv1.PolicyRule{
+			APIGroups: []string{key.apiGroup},
+			Resources: []string{key.name},
+		}
+		for verb := range verbSet {
+			rule.Verbs = append(rule.Verbs, verb)
+		}
+		sort.Strings(rule.Verbs)
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].APIGroups[0] != rules[j].APIGroups[0] {
+			return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+		}
+		return rules[i].Resources[0] < rules[j].Resources[0]
+	})
+	return
+}
+
+// Manifest returns a multi-document YAML manifest containing a ServiceAccount in the provided namespace,
+// and the ClusterRole and ClusterRoleBinding required to run all probes with it
+func Manifest(namespace string) ([]byte, error) {
+	objects := []interface{}{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name},
+			Rules:      Rules(),
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     Name,
+			},
+			Subjects: []rbacv1.Subject{
+				{Kind: "ServiceAccount", Name: Name, Namespace: namespace},
+			},
+		},
+		&apiv1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: Name, Namespace: namespace},
+		},
+	}
+
+	var manifest bytes.Buffer
+	for i, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			manifest.WriteString("---\n")
+		}
+		manifest.Write(bytes.Replace(data, []byte("  creationTimestamp: null\n"), nil, -1))
+	}
+	return manifest.Bytes(), nil
+}
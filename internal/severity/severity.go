@@ -0,0 +1,66 @@
+// Package severity provides the logic required to rank scenario failures by the severity tags in each feature file
+package severity
+
+import (
+	"strings"
+
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// TagPrefix is used by feature files to declare the severity of a scenario, such as @severity-critical
+const TagPrefix = "@severity-"
+
+// DefaultLevel is applied to any scenario that does not have a severity tag
+const DefaultLevel = "high"
+
+// Levels lists all supported severities, from lowest to highest
+var Levels = []string{"low", "medium", "high", "critical"}
+
+// Rank returns the position of the provided level within Levels
+func Rank(level string) (int, error) {
+	if i, found := utils.FindString(Levels, strings.ToLower(level)); found {
+		return i, nil
+	}
+	return -1, utils.ReformatError("Unexpected severity level '%s'. Expected values: %v", level, Levels)
+}
+
+// FromTags returns the severity declared in the provided scenario tags, or DefaultLevel if none is found
+func FromTags(tags []string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, TagPrefix) {
+			level := strings.TrimPrefix(tag, TagPrefix)
+			if _, err := Rank(level); err == nil {
+				return strings.ToLower(level)
+			}
+		}
+	}
+	return DefaultLevel
+}
+
+// Evaluate counts failed scenarios per severity level, logs the counts to the summary,
+// and returns the number of failures at or above the provided threshold
+func Evaluate(state *audit.SummaryState, threshold string) (blocking int, err error) {
+	minimum, err := Rank(threshold)
+	if err != nil {
+		return
+	}
+	failures := make(map[string]int)
+	for _, level := range Levels {
+		failures[level] = 0
+	}
+	for _, probe := range state.Probes {
+		for _, scenario := range probe.Scenarios {
+			if scenario.Result != "Failed" {
+				continue
+			}
+			level := FromTags(scenario.Tags)
+			failures[level] = failures[level] + 1
+			if rank, _ := Rank(level); rank >= minimum {
+				blocking++
+			}
+		}
+	}
+	state.Meta["failures_by_severity"] = failures
+	return
+}
@@ -0,0 +1,95 @@
+package severity
+
+import (
+	"testing"
+
+	messages "github.com/cucumber/messages-go/v10"
+	audit "github.com/probr/probr-sdk/audit"
+)
+
+func TestRank(t *testing.T) {
+	for _, test := range []struct {
+		level string
+		rank  int
+		err   bool
+	}{
+		{level: "low", rank: 0},
+		{level: "medium", rank: 1},
+		{level: "high", rank: 2},
+		{level: "critical", rank: 3},
+		{level: "Critical", rank: 3},
+		{level: "severe", rank: -1, err: true},
+		{level: "", rank: -1, err: true},
+	} {
+		rank, err := Rank(test.level)
+		if rank != test.rank || (err != nil) != test.err {
+			t.Errorf("Rank(%q): expected %d (error %v), found %d (%v)", test.level, test.rank, test.err, rank, err)
+		}
+	}
+}
+
+func TestFromTags(t *testing.T) {
+	for _, test := range []struct {
+		tags     []string
+		expected string
+	}{
+		{tags: []string{"@k-pod-001", "@severity-critical"}, expected: "critical"},
+		{tags: []string{"@k-pod-001", "@severity-LOW"}, expected: "low"},
+		{tags: []string{"@severity-Medium", "@k-pod-001"}, expected: "medium"},
+		{tags: []string{"@severity-severe", "@severity-low"}, expected: "low"},
+		{tags: []string{"@severity-severe"}, expected: DefaultLevel},
+		{tags: []string{"@k-pod-001"}, expected: DefaultLevel},
+		{tags: nil, expected: DefaultLevel},
+	} {
+		if level := FromTags(test.tags); level != test.expected {
+			t.Errorf("FromTags(%v): expected '%s', found '%s'", test.tags, test.expected, level)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	state := audit.NewSummaryState("kubernetes")
+	probe := state.GetProbeLog("podsecurity")
+	for _, scenario := range []struct {
+		severity string
+		result   string
+	}{
+		{"@severity-low", "Failed"},
+		{"@severity-Medium", "Failed"},
+		{"@severity-high", "Passed"},
+		{"@severity-critical", "Failed"},
+		{"@severity-critical", "Inconclusive"},
+		{"@severity-critical", "Waived"},
+		{"", "Failed"}, // DefaultLevel
+	} {
+		var tags []*messages.Pickle_PickleTag
+		if scenario.severity != "" {
+			tags = append(tags, &messages.Pickle_PickleTag{Name: scenario.severity})
+		}
+		probe.InitializeAuditor("Scenario", tags).Result = scenario.result
+	}
+
+	for _, test := range []struct {
+		threshold string
+		blocking  int
+		err       bool
+	}{
+		{threshold: "low", blocking: 4},
+		{threshold: "medium", blocking: 3},
+		{threshold: "high", blocking: 2},
+		{threshold: "critical", blocking: 1},
+		{threshold: "CRITICAL", blocking: 1},
+		{threshold: "severe", err: true},
+	} {
+		blocking, err := Evaluate(&state, test.threshold)
+		if blocking != test.blocking || (err != nil) != test.err {
+			t.Errorf("Evaluate(%q): expected %d blocking failures (error %v), found %d (%v)", test.threshold, test.blocking, test.err, blocking, err)
+		}
+	}
+	failures := state.Meta["failures_by_severity"].(map[string]int)
+	for level, expected := range map[string]int{"low": 1, "medium": 1, "high": 1, "critical": 1} {
+		if failures[level] != expected {
+			t.Errorf("Expected %d '%s' failures, found %d", expected, level, failures[level])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cucumber/godog"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// defaultExternalCheckTimeout is used when an external check does not specify its own timeout
+const defaultExternalCheckTimeout = 5 * time.Minute
+
+// theExternalCheckXPasses runs the named command from ExternalChecks.
+// Exit code 0 passes the step, 1 fails it, and any other result is audited as inconclusive.
+func (s *ScenarioState) theExternalCheckXPasses(name string) error {
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	check, found := config.Vars.ServicePacks.Kubernetes.ExternalChecks[name]
+	if !found || check.Command == "" {
+		err = utils.ReformatError("No command is configured for external check '%s'", name)
+		return err
+	}
+	timeout := defaultExternalCheckTimeout
+	if check.Timeout != "" {
+		timeout, err = time.ParseDuration(check.Timeout)
+		if err != nil {
+			err = utils.ReformatError("Invalid timeout '%s' for external check '%s': %v", check.Timeout, name, err)
+			return err
+		}
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Run external check '%s'; ", name))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, check.Command, check.Args...)
+	cmd.Env = append(os.Environ(),
+		"KUBECONFIG="+config.Vars.ServicePacks.Kubernetes.KubeConfigPath,
+		"KUBE_CONTEXT="+config.Vars.ServicePacks.Kubernetes.KubeContext,
+		"PROBR_K8S_PROBE_NAMESPACE="+s.Namespace,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	exitCode := cmd.ProcessState.ExitCode()
+
+	result := struct {
+		Name     string
+		Command  string
+		Args     []string
+		ExitCode int
+		Stdout   string
+		Stderr   string
+		RunError string `json:",omitempty"`
+	}{
+		Name:     name,
+		Command:  check.Command,
+		Args:     check.Args,
+		ExitCode: exitCode,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}
+	if runErr != nil {
+		result.RunError = runErr.Error()
+	}
+	payload = result
+
+	stepTrace.WriteString("Validate the exit code of the external check; ")
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = Inconclusive("External check '%s' did not complete within %s", name, timeout)
+	case exitCode == 0:
+		return nil
+	case exitCode == 1:
+		err = utils.ReformatError("External check '%s' failed. Please review audit output for more information.", name)
+		return err
+	case exitCode == -1:
+		err = Inconclusive("External check '%s' could not be executed: %v", name, runErr)
+	default:
+		err = Inconclusive("External check '%s' was inconclusive with exit code %d", name, exitCode)
+	}
+	return godog.ErrPending
+}
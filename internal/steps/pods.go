@@ -0,0 +1,226 @@
+package steps
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/egress"
+	"github.com/probr/probr-pack-kubernetes/internal/toolkit"
+	"github.com/probr/probr-sdk/utils"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+const (
+	// listenSeconds bounds how long the target pod listens for a connection from the source pod
+	listenSeconds = 20
+
+	// connectAttempts allows the listener time to start before the connection is considered to have failed
+	connectAttempts = 3
+)
+
+func (s *ScenarioState) podXIsCreatedWithYSetToZInThePodSpec(alias, key, value string) (err error) {
+	// Supports the same key/values as BuildPod,
+	// but the pod must be created successfully and is tracked using the provided name
+
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString("Build a pod spec with default values; ")
+	pod, err := BuildPod(s.ProbeName, key, value)
+	if err != nil {
+		return
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create pod '%s' from spec; ", alias))
+	createdPod, creationErr := s.Pods.Create(alias, pod, s.ProbeName)
+	if creationErr != nil {
+		err = utils.ReformatError("Pod '%s' creation did not succeed: %v", alias, creationErr)
+	}
+
+	payload = struct {
+		PodAlias      string
+		RequestedPod  *apiv1.Pod
+		CreatedPod    *apiv1.Pod
+		CreationError error
+		Mutations     []admission.Mutation
+	}{
+		PodAlias:      alias,
+		RequestedPod:  pod,
+		CreatedPod:    createdPod,
+		CreationError: creationErr,
+		Mutations:     admission.Mutations(pod, createdPod),
+	}
+	return
+}
+
+func (s *ScenarioState) podXIsCreated(alias string) error {
+	return s.podXIsCreatedWithYSetToZInThePodSpec(alias, "", "not have a value provided")
+}
+
+func (s *ScenarioState) theExecutionOfAXCommandInsidePodYIsZ(cmdType, alias, result string) error {
+	// Supports the same command types and results as CommandForType
+
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	pod, err := s.Pods.Named(alias)
+	if err != nil {
+		return err
+	}
+	cmd, expectedExitCodes, err := CommandForType(cmdType, result)
+	if err != nil {
+		return err // No payload is necessary if an invalid value was provided
+	}
+	stepTrace.WriteString(fmt.Sprintf("Attempt to run a command in pod '%s'; ", alias))
+	exitCode, stdout, stderr, err := connection.State.ExecCommand(cmd, pod.Namespace, pod.Name)
+
+	payload = struct {
+		PodAlias          string
+		PodName           string
+		Command           string
+		StdOut            string
+		StdErr            string
+		ExecErr           error
+		ExitCode          int
+		ExpectedExitCodes []int
+		ToolkitImage      string `json:",omitempty"`
+	}{
+		PodAlias:          alias,
+		PodName:           pod.Name,
+		Command:           cmd,
+		StdOut:            stdout,
+		StdErr:            stderr,
+		ExecErr:           err,
+		ExitCode:          exitCode,
+		ExpectedExitCodes: expectedExitCodes,
+		ToolkitImage:      toolkit.Image(),
+	}
+
+	err = ValidateExitCode(cmd, exitCode, expectedExitCodes, err) // Must be assigned to 'err' to be audited
+	return err
+}
+
+// podConnection is the audit payload for the pod-to-pod connection step
+type podConnection struct {
+	SourcePod        string
+	TargetPod        string
+	TargetIP         string
+	Port             int
+	ListenCommand    string
+	ListenExitCode   int
+	ListenExecErr    error
+	ConnectCommand   string
+	ConnectExitCodes []int
+	ConnectStdErr    string
+	Reached          bool
+	ToolkitImage     string `json:",omitempty"`
+}
+
+func (s *ScenarioState) theConnectionFromPodXToPodYOnPortZIsW(source, target, port, result string) (err error) {
+	// Supported results:
+	//     'successful'
+	//     'prevented'
+	//
+	// The target pod listens on the port with nc for the duration of the step, so no service is required in the image
+
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	var shouldReach bool
+	switch result {
+	case "successful":
+		shouldReach = true
+	case "prevented":
+		shouldReach = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expected connection result: %s", result)
+		return
+	}
+	portNumber, convErr := strconv.Atoi(port)
+	if convErr != nil || portNumber < 1 || portNumber > 65535 {
+		err = utils.ReformatError("Expected a port number, but found '%s'", port)
+		return
+	}
+	sourcePod, err := s.Pods.Named(source)
+	if err != nil {
+		return
+	}
+	targetPod, err := s.Pods.Named(target)
+	if err != nil {
+		return
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Retrieve the IP of pod '%s'; ", target))
+	targetIP, _, err := connection.State.GetPodIPs(targetPod.Namespace, targetPod.Name)
+	if err != nil {
+		return
+	}
+
+	attempt := &podConnection{
+		SourcePod:      sourcePod.Name,
+		TargetPod:      targetPod.Name,
+		TargetIP:       targetIP,
+		Port:           portNumber,
+		ListenCommand:  fmt.Sprintf("%s %s", toolkit.Command(fmt.Sprintf("timeout %d", listenSeconds)), toolkit.Command(fmt.Sprintf("nc -l -p %d", portNumber))),
+		ConnectCommand: toolkit.Command(fmt.Sprintf("nc -z -w 5 %s %d", targetIP, portNumber)),
+		ToolkitImage:   toolkit.Image(),
+	}
+	payload = attempt
+
+	stepTrace.WriteString(fmt.Sprintf("Listen on port %d in pod '%s'; ", portNumber, target))
+	listening := make(chan struct{})
+	go func() {
+		attempt.ListenExitCode, _, _, attempt.ListenExecErr = connection.State.ExecCommand(attempt.ListenCommand, targetPod.Namespace, targetPod.Name)
+		close(listening)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Attempt a tcp connection from pod '%s' to pod '%s'; ", source, target))
+	for i := 0; i < connectAttempts && !attempt.Reached; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		exitCode, _, stderr, _ := connection.State.ExecCommand(attempt.ConnectCommand, sourcePod.Namespace, sourcePod.Name)
+		attempt.ConnectExitCodes = append(attempt.ConnectExitCodes, exitCode)
+		attempt.ConnectStdErr = stderr
+		if egress.ToolMissing(exitCode) {
+			err = utils.ReformatError("nc is not available in pod '%s' (exit code %d). Consider configuring a ToolkitImage.", source, exitCode)
+			break
+		}
+		attempt.Reached = exitCode == 0
+	}
+	<-listening
+	if err != nil {
+		return
+	}
+
+	stepTrace.WriteString("Validate that the listener was running; ")
+	switch {
+	case egress.ToolMissing(attempt.ListenExitCode):
+		err = utils.ReformatError("nc is not available in pod '%s' (exit code %d). Consider configuring a ToolkitImage.", target, attempt.ListenExitCode)
+	case attempt.ListenExecErr != nil && attempt.ListenExitCode == 0:
+		err = utils.ReformatError("Could not start the listener in pod '%s': %v", target, attempt.ListenExecErr)
+	case !attempt.Reached && !listenTimedOut(attempt.ListenExitCode):
+		// A connection can only be shown to be prevented if the listener was running until it timed out
+		err = utils.ReformatError("The listener in pod '%s' exited with code %d before timing out", target, attempt.ListenExitCode)
+	}
+	if err != nil {
+		return
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Validate that the connection was %s; ", result))
+	if attempt.Reached && !shouldReach {
+		err = utils.ReformatError("Pod '%s' reached pod '%s' on port %d, but should not have", source, target, portNumber)
+	} else if !attempt.Reached && shouldReach {
+		err = utils.ReformatError("Pod '%s' could not reach pod '%s' on port %d (exit codes %v)", source, target, portNumber, attempt.ConnectExitCodes)
+	}
+	return
+}
+
+// listenTimedOut returns true if the exit code shows that the listener was stopped by timeout,
+// which exits with 124 (coreutils) or 143 (busybox, terminated by SIGTERM)
+func listenTimedOut(exitCode int) bool {
+	return exitCode == 124 || exitCode == 143
+}
@@ -0,0 +1,163 @@
+package steps
+
+import (
+	"strconv"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/toolkit"
+	"github.com/probr/probr-sdk/providers/kubernetes/constructors"
+	"github.com/probr/probr-sdk/utils"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// BuildPod returns the default probe pod spec, modified according to the provided key and value
+func BuildPod(probeName, key, value string) (pod *apiv1.Pod, err error) {
+	pod = constructors.PodSpec(probeName, config.Vars.ServicePacks.Kubernetes.ProbeNamespace, config.Vars.ServicePacks.Kubernetes.AuthorisedContainerImage)
+
+	// Any key that expects a non-bool value should have it's own case here to handle the pod modification
+
+	switch key {
+	case "user":
+		err = userPodSpecModifier(pod, value)
+	case "annotations":
+		err = annotationsPodSpecModifier(pod, value)
+	case "seccompProfile":
+		err = seccompProfilePodSpecModifier(pod, value)
+	case "capabilities":
+		err = capabilitiesPodSpecModifier(pod, value)
+	default:
+		if value == "true" || value == "false" {
+			err = boolPodSpecModifier(pod, key, value)
+		} else if value != "not have a value provided" {
+			err = utils.ReformatError("Expected 'true', 'false', or 'not have a value provided', but found '%s'", value)
+		}
+	}
+	return
+}
+
+// CommandForType returns the command to execute for the provided command type, and the exit codes expected for the result
+func CommandForType(cmdType, result string) (cmd string, expectedExitCodes []int, err error) {
+	var expectedFailureCodes []int
+	switch cmdType {
+	case "non-privileged":
+		cmd = "ls"
+	case "privileged":
+		cmd = "mount /fake /fake"
+		expectedFailureCodes = []int{1, 32}
+	case "root":
+		cmd = "touch /dev/probr"
+		expectedFailureCodes = []int{1}
+	case "ping":
+		cmd = "ping -w 4 google.com"
+		expectedFailureCodes = []int{1, 2}
+	default:
+		err = utils.ReformatError("Unexpected value provided for command type: %s", cmdType)
+		return
+	}
+
+	switch result {
+	case "successful":
+		expectedExitCodes = []int{0}
+	case "prevented":
+		expectedExitCodes = expectedFailureCodes
+	default:
+		err = utils.ReformatError("Unexpected value provided for expected command result: %s", result)
+	}
+	cmd = toolkit.Command(cmd)
+	return
+}
+
+// ValidateExitCode returns nil if the exit code was expected, regardless of the error returned by the exec request
+func ValidateExitCode(cmd string, exitCode int, expectedExitCodes []int, err error) error {
+	var exitKnown bool
+	for _, expectedCode := range expectedExitCodes {
+		if exitCode == expectedCode {
+			exitKnown = true
+			err = nil
+		}
+	}
+	if !exitKnown && exitCode == 0 {
+		err = utils.ReformatError("'%s' succeeded with exit code %d, but shouldn't have.", cmd, exitCode)
+	} else if !exitKnown {
+		err = utils.ReformatError("Unexpected exit code: %d. Please review audit output for more information.", exitCode)
+	}
+	return err
+}
+
+func boolPodSpecModifier(pod *apiv1.Pod, key, value string) (err error) {
+	// Supported keys:
+	//     'allowPrivilegeEscalation'
+	//     'hostPID'
+	//     'hostIPC'
+	//     'hostNetwork'
+	// Supported values:
+	//     'true'
+	//     'false'
+
+	boolValue, _ := strconv.ParseBool(value)
+	switch key {
+	case "allowPrivilegeEscalation":
+		pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation = &boolValue
+	case "hostPID":
+		pod.Spec.HostPID = boolValue
+	case "hostIPC":
+		pod.Spec.HostIPC = boolValue
+	case "hostNetwork":
+		pod.Spec.HostNetwork = boolValue
+	default:
+		err = utils.ReformatError("Unsupported key provided: %s", key) // No payload is necessary if an invalid key was provided
+	}
+	return
+}
+
+func annotationsPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
+	switch value {
+	case "include seccomp profile":
+		return // default
+	case "not include seccomp profile":
+		pod.ObjectMeta.Annotations = nil
+	default:
+		err = utils.ReformatError("Expected 'include seccomp profile' or 'not include seccomp profile', but found '%s'", value) // No payload is necessary if an invalid value was provided
+	}
+	return
+}
+
+// seccompProfilePodSpecModifier sets the pod's seccompProfile field, which replaced the seccomp annotations
+// in Kubernetes 1.19. The default annotation is removed, so that only the field is evaluated.
+func seccompProfilePodSpecModifier(pod *apiv1.Pod, value string) (err error) {
+	pod.ObjectMeta.Annotations = nil
+	switch value {
+	case "RuntimeDefault", "Unconfined":
+		pod.Spec.SecurityContext.SeccompProfile = &apiv1.SeccompProfile{Type: apiv1.SeccompProfileType(value)}
+	case "not have a value provided":
+		pod.Spec.SecurityContext.SeccompProfile = nil
+	default:
+		err = utils.ReformatError("Expected 'RuntimeDefault', 'Unconfined' or 'not have a value provided', but found '%s'", value) // No payload is necessary if an invalid value was provided
+	}
+	return
+}
+
+func capabilitiesPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
+	switch value {
+	case "drop NET_RAW":
+		// default probe pod does this already
+	case "add NET_RAW":
+		pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = []apiv1.Capability{} // clear default cap drop
+		pod.Spec.Containers[0].SecurityContext.Capabilities.Add = append(pod.Spec.Containers[0].SecurityContext.Capabilities.Add, "NET_RAW")
+	case "not have a value provided":
+		pod.Spec.Containers[0].SecurityContext.Capabilities.Drop = []apiv1.Capability{}
+	default:
+		err = utils.ReformatError("Expected 'include NET_RAW' or 'not include NET_RAW', but found '%s'", value) // No payload is necessary if an invalid value was provided
+	}
+	return
+}
+
+func userPodSpecModifier(pod *apiv1.Pod, value string) (err error) {
+	intValue, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		err = utils.ReformatError("Expected value to be a whole number, but found '%s' (%s)", value, err) // No payload is necessary if an invalid value was provided
+		return err
+	}
+	pod.Spec.SecurityContext.RunAsUser = &intValue
+	return
+}
@@ -0,0 +1,213 @@
+// Package steps provides the step library shared by all probes. Each probe registers its step definitions here,
+// so that any scenario, including those in custom feature files, can combine steps from every probe.
+package steps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	"github.com/probr/probr-pack-kubernetes/internal/admission"
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/pods"
+	"github.com/probr/probr-pack-kubernetes/internal/summary"
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-sdk/utils"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// ScenarioState holds the state shared by all steps within the scenario that is currently executing
+type ScenarioState struct {
+	Name        string
+	ProbeName   string
+	CurrentStep string
+	Namespace   string
+	ProbeAudit  *audit.Probe
+	Audit       *audit.Scenario
+	Pods        *pods.Tracker
+	Denial      *admission.DenialReason // The most recent pod creation denial checked by ValidateDenial
+}
+
+// Scenario is the state of the scenario that is currently executing.
+// Scenarios are executed sequentially, so a single state is shared by every step definition.
+var Scenario ScenarioState
+
+// registry holds the step definitions registered by each probe
+var registry []func(ctx *godog.ScenarioContext)
+
+// Register adds step definitions to the library. Probes should call this from init().
+func Register(stepDefinitions func(ctx *godog.ScenarioContext)) {
+	registry = append(registry, stepDefinitions)
+}
+
+// Initialize adds the shared scenario hooks and every step definition in the library to the context of the named probe
+func Initialize(ctx *godog.ScenarioContext, probeName string) {
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		Scenario.beforeScenario(probeName, s)
+	})
+
+	// Background
+	ctx.Step(`^a Kubernetes cluster exists which we can deploy into$`, Scenario.aKubernetesClusterIsDeployed)
+
+	// Use for steps that have yet to be written
+	ctx.Step(`^TODO: "([^"]*)"$`, Scenario.toDo)
+
+	// Assert which admission control denied the most recent pod creation
+	ctx.Step(`^the denial came from "([^"]*)"$`, Scenario.theDenialCameFromX)
+
+	// Organisation-specific checks configured in ExternalChecks
+	ctx.Step(`^the external check "([^"]*)" passes$`, Scenario.theExternalCheckXPasses)
+
+	// Steps that refer to pods by name, for scenarios that use more than one pod
+	ctx.Step(`^pod "([^"]*)" is created$`, Scenario.podXIsCreated)
+	ctx.Step(`^pod "([^"]*)" is created with "([^"]*)" set to "([^"]*)" in the pod spec$`, Scenario.podXIsCreatedWithYSetToZInThePodSpec)
+	ctx.Step(`^the execution of (?:a )?"([^"]*)" (?:command )?(?:inside|in) pod "([^"]*)" is "([^"]*)"$`, Scenario.theExecutionOfAXCommandInsidePodYIsZ)
+	ctx.Step(`^the connection from pod "([^"]*)" to pod "([^"]*)" on port "([^"]*)" is "([^"]*)"$`, Scenario.theConnectionFromPodXToPodYOnPortZIsW)
+
+	for _, stepDefinitions := range registry {
+		stepDefinitions(ctx)
+	}
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		Scenario.afterScenario(s)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		Scenario.CurrentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		Scenario.CurrentStep = ""
+	})
+}
+
+// InconclusiveResult is audited for steps, and the scenarios containing them, that could not determine
+// whether the control is in place. Inconclusive scenarios are neither passed nor failed.
+const InconclusiveResult = summary.InconclusiveResult
+
+// InconclusiveError is audited as an inconclusive result rather than a failure
+type InconclusiveError struct {
+	message string
+}
+
+func (e *InconclusiveError) Error() string {
+	return e.message
+}
+
+// Inconclusive returns an error that is audited as inconclusive. Steps should assign it to the audited error
+// and return godog.ErrPending, so that the step is also reported as pending by godog.
+func Inconclusive(format string, a ...interface{}) error {
+	return &InconclusiveError{message: fmt.Sprintf(format, a...)}
+}
+
+// AuditStep records the result of a step in the scenario audit, including any panic raised by the step.
+// It must be deferred directly by the step function so that the panic can be recovered.
+// An InconclusiveError is audited as inconclusive, and is returned to godog as godog.ErrPending by steps
+// that use a named error return.
+func (s *ScenarioState) AuditStep(stepTrace *strings.Builder, payload *interface{}, err *error) {
+	if panicErr := recover(); panicErr != nil {
+		*err = utils.ReformatError("[ERROR] Unexpected behavior occured: %s", panicErr)
+	}
+	if *err == godog.ErrPending {
+		*err = Inconclusive("The step is pending")
+	}
+	s.Audit.AuditScenarioStep(s.CurrentStep, stepTrace.String(), *payload, *err)
+	if _, inconclusive := (*err).(*InconclusiveError); inconclusive {
+		s.Audit.Steps[len(s.Audit.Steps)].Result = InconclusiveResult
+		s.Audit.Result = InconclusiveResult
+		*err = godog.ErrPending
+	}
+}
+
+// CreatePodFromObject creates an unnamed pod, which is deleted when the scenario ends
+func (s *ScenarioState) CreatePodFromObject(podObject *apiv1.Pod) (createdPodObject *apiv1.Pod, err error) {
+	return s.Pods.Create("", podObject, s.ProbeName)
+}
+
+// ValidateDenial records the reason that pod creation was denied, and returns false if the denial did not come
+// from one of the AdmissionControllers. Steps should then audit an Inconclusive error and return godog.ErrPending.
+func (s *ScenarioState) ValidateDenial(creationErr error, stepTrace *strings.Builder) (reason *admission.DenialReason, accepted bool) {
+	reason = admission.ParseDenial(creationErr)
+	s.Denial = reason
+	stepTrace.WriteString(fmt.Sprintf("Check that pod creation was denied by an accepted admission control; Denied by %s; ", reason))
+	accepted = reason.Accepted()
+	if !accepted {
+		stepTrace.WriteString(fmt.Sprintf("The result is inconclusive, as %s is not one of the AdmissionControllers: %s; ",
+			reason.Controller, strings.Join(config.Vars.ServicePacks.Kubernetes.AdmissionControllers, ", ")))
+	}
+	return
+}
+
+func (s *ScenarioState) theDenialCameFromX(expected string) error {
+	// Supported values for expected:
+	//	A controller such as 'PodSecurity', 'Gatekeeper' or 'Kyverno', optionally followed by '/<policy name>'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString(fmt.Sprintf("Compare the most recent denial to '%s'; ", expected))
+	payload = struct {
+		Expected     string
+		DenialReason *admission.DenialReason
+	}{expected, s.Denial}
+
+	if s.Denial == nil {
+		err = utils.ReformatError("No pod creation was denied in a previous step")
+	} else if !s.Denial.Matches(expected) {
+		err = utils.ReformatError("Pod creation was denied by %s, not '%s'", s.Denial, expected)
+	}
+	return err
+}
+
+func (s *ScenarioState) aKubernetesClusterIsDeployed() error {
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString("Validate that a cluster can be reached using the specified kube config and context; ")
+
+	payload = struct {
+		KubeConfigPath string
+		KubeContext    string
+	}{
+		config.Vars.ServicePacks.Kubernetes.KubeConfigPath,
+		config.Vars.ServicePacks.Kubernetes.KubeContext,
+	}
+
+	err = connection.State.ClusterIsDeployed() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (s *ScenarioState) toDo(todo string) error {
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer s.AuditStep(&stepTrace, &payload, &err)
+
+	stepTrace.WriteString("This step was included to inform developers that a scenario is incomplete; ")
+	payload = struct {
+		TODO string
+	}{TODO: todo}
+	err = Inconclusive("The step has yet to be written: %s", todo)
+	return godog.ErrPending
+}
+
+func (s *ScenarioState) beforeScenario(probeName string, gs *godog.Scenario) {
+	s.Name = gs.Name
+	s.ProbeName = probeName
+	s.ProbeAudit = summary.State.GetProbeLog(probeName)
+	s.Audit = summary.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.Pods = pods.NewTracker()
+	s.Denial = nil
+	s.Namespace = config.Vars.ServicePacks.Kubernetes.ProbeNamespace
+	probeengine.LogScenarioStart(gs)
+}
+
+func (s *ScenarioState) afterScenario(gs *godog.Scenario) {
+	if config.Vars.ServicePacks.Kubernetes.KeepPods == "false" {
+		s.Pods.DeleteAll(s.ProbeName)
+	}
+	probeengine.LogScenarioEnd(gs)
+}
@@ -6,3 +6,44 @@ import (
 
 // State should be set in the pack's runtime via audit.NewSummaryState
 var State audit.SummaryState
+
+// InconclusiveResult is audited for scenarios that could not determine whether the control is in place
+const InconclusiveResult = "Inconclusive"
+
+// CountInconclusive discounts inconclusive scenarios from the scenarios attempted by each probe, then derives the
+// probe result again in the same way as the SDK completes a probe. The SDK only counts passed, failed and
+// Given Not Met scenarios, so it fails any probe with an inconclusive scenario.
+// A probe whose scenarios were all inconclusive is neither passed nor failed, and is counted as skipped.
+func CountInconclusive(state *audit.SummaryState) {
+	for _, probe := range state.Probes {
+		if probe.Result != "Failed" {
+			continue
+		}
+		var inconclusive int
+		for _, scenario := range probe.Scenarios {
+			if scenario.Result == InconclusiveResult {
+				inconclusive++
+			}
+		}
+		if inconclusive == 0 {
+			continue
+		}
+		probe.ScenariosAttempted = probe.ScenariosAttempted - inconclusive
+		probe.Meta["scenarios_inconclusive"] = inconclusive
+		switch probe.ScenariosAttempted {
+		case 0:
+			probe.Result = InconclusiveResult
+			state.ProbesSkipped = state.ProbesSkipped + 1
+			state.ProbesFailed = state.ProbesFailed - 1
+		case probe.ScenariosSucceeded:
+			probe.Result = "Success"
+			state.ProbesPassed = state.ProbesPassed + 1
+			state.ProbesFailed = state.ProbesFailed - 1
+		case probe.GivenNotMet:
+			probe.Result = "Given was Not Met"
+			state.ProbesSkipped = state.ProbesSkipped + 1
+			state.ProbesFailed = state.ProbesFailed - 1
+		}
+		probe.Write() // Overwrite the audit file written during probe completion
+	}
+}
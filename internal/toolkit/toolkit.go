@@ -0,0 +1,65 @@
+// Package toolkit injects a statically linked toolbox into probe pods, so that exec-based steps
+// can run on minimal images that do not include tools such as curl, ping or mount
+package toolkit
+
+import (
+	"strings"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// MountPath is the directory in every probe container where the toolkit's tools are available
+const MountPath = "/probr/toolkit"
+
+const volumeName = "probr-toolkit"
+
+// Enabled returns true if a toolkit image has been configured
+func Enabled() bool {
+	return config.Vars.ServicePacks.Kubernetes.ToolkitImage != ""
+}
+
+// Image returns the configured toolkit image, for recording in audit payloads
+func Image() string {
+	return config.Vars.ServicePacks.Kubernetes.ToolkitImage
+}
+
+// Inject adds an init container that copies the toolkit into an emptyDir volume shared with every container in the pod
+func Inject(pod *apiv1.Pod) {
+	if !Enabled() || pod == nil {
+		return
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, apiv1.Volume{
+		Name:         volumeName,
+		VolumeSource: apiv1.VolumeSource{EmptyDir: &apiv1.EmptyDirVolumeSource{}},
+	})
+	mount := apiv1.VolumeMount{Name: volumeName, MountPath: MountPath}
+
+	// -L copies the targets of symlinks, as the tools in images such as busybox are links to a multi-call binary
+	initContainer := apiv1.Container{
+		Name:            volumeName,
+		Image:           config.Vars.ServicePacks.Kubernetes.ToolkitImage,
+		ImagePullPolicy: apiv1.PullIfNotPresent,
+		Command:         []string{"cp", "-R", "-L", strings.TrimSuffix(config.Vars.ServicePacks.Kubernetes.ToolkitSourceDir, "/") + "/.", MountPath},
+		VolumeMounts:    []apiv1.VolumeMount{mount},
+	}
+	if len(pod.Spec.Containers) > 0 && pod.Spec.Containers[0].SecurityContext != nil {
+		// The init container must be admitted by the same policies as the probe container
+		initContainer.SecurityContext = pod.Spec.Containers[0].SecurityContext.DeepCopy()
+	}
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainer)
+
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].VolumeMounts = append(pod.Spec.Containers[i].VolumeMounts, mount)
+	}
+}
+
+// Command prefixes the executable in the command with the toolkit path, if a toolkit is enabled.
+// Only the first word is prefixed, so commands that run another executable, such as 'timeout <seconds> nc',
+// must prefix each one with its own call to Command.
+func Command(command string) string {
+	if !Enabled() || strings.HasPrefix(command, "/") {
+		return command
+	}
+	return MountPath + "/" + command
+}
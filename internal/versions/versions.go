@@ -0,0 +1,198 @@
+// Package versions skips scenarios that do not apply to the cluster's Kubernetes version.
+// Scenarios are gated with tags such as @k8s-gte-1.25 (1.25 or later) and @k8s-lt-1.25 (earlier than 1.25).
+package versions
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/probr/probr-sdk/probeengine"
+	"github.com/probr/probr-sdk/utils"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Version is a Kubernetes minor release, such as 1.25
+type Version struct {
+	Major int
+	Minor int
+}
+
+// SkippedScenario is logged to the summary for each scenario excluded by a version tag
+type SkippedScenario struct {
+	Probe    string
+	Scenario string
+	Tags     []string
+	Reason   string
+}
+
+var (
+	versionTag    = regexp.MustCompile(`^@k8s-(gte|lt)-(.+)$`)
+	versionNumber = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)`)
+)
+
+// Parse reads the major and minor version from a string such as "1.25", "v1.25.3" or "v1.25.3-gke.100"
+func Parse(value string) (v Version, err error) {
+	match := versionNumber.FindStringSubmatch(value)
+	if match == nil {
+		return v, utils.ReformatError("Expected a version such as '1.25', but found '%s'", value)
+	}
+	v.Major, _ = strconv.Atoi(match[1])
+	v.Minor, _ = strconv.Atoi(match[2])
+	return
+}
+
+// FromInfo returns the version reported by the API server. The GitVersion is preferred, as
+// some providers report a minor version such as "25+".
+func FromInfo(info *version.Info) (Version, error) {
+	if info == nil {
+		return Version{}, utils.ReformatError("No server version was provided")
+	}
+	v, err := Parse(info.GitVersion)
+	if err != nil {
+		v, err = Parse(strings.TrimRight(info.Major, "+") + "." + strings.TrimRight(info.Minor, "+"))
+	}
+	return v, err
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Less returns true if v is an earlier release than other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// Applies returns true if the tag is not a version tag, or if the server version meets it.
+// Otherwise the reason that the tag does not apply is returned.
+func Applies(tag string, server Version) (applies bool, reason string) {
+	match := versionTag.FindStringSubmatch(tag)
+	if match == nil {
+		return true, ""
+	}
+	required, err := Parse(match[2])
+	if err != nil {
+		log.Printf("[WARN] Ignoring invalid version tag '%s': %v", tag, err)
+		return true, ""
+	}
+	switch {
+	case match[1] == "gte" && server.Less(required):
+		return false, fmt.Sprintf("requires Kubernetes %s or later, but the cluster is running %s", required, server)
+	case match[1] == "lt" && !server.Less(required):
+		return false, fmt.Sprintf("requires Kubernetes earlier than %s, but the cluster is running %s", required, server)
+	}
+	return true, ""
+}
+
+// Gate reads the feature files of each probe, and returns the version tags that do not apply to the server
+// version, to be excluded from the run, along with the scenarios that will be skipped as a result
+func Gate(probes []probeengine.Probe, server Version) (exclusions []string, skipped []SkippedScenario) {
+	excluded := make(map[string]bool)
+	for _, probe := range probes {
+		for _, scenario := range scenarios(probe.Path()) {
+			var reasons []string
+			for _, tag := range scenario.tags {
+				if applies, reason := Applies(tag, server); !applies {
+					reasons = append(reasons, reason)
+					if !excluded[tag] {
+						excluded[tag] = true
+						exclusions = append(exclusions, strings.TrimPrefix(tag, "@"))
+					}
+				}
+			}
+			if len(reasons) > 0 {
+				log.Printf("[INFO] Skipping scenario '%s', as it %s", scenario.name, strings.Join(reasons, " and "))
+				skipped = append(skipped, SkippedScenario{
+					Probe:    probe.Name(),
+					Scenario: scenario.name,
+					Tags:     scenario.tags,
+					Reason:   strings.Join(reasons, "; "),
+				})
+			}
+		}
+	}
+	return
+}
+
+type taggedScenario struct {
+	name string
+	tags []string
+}
+
+// scenarios lists the scenarios in the feature file, or in every feature file below the directory, with the
+// tags that apply to them. An Examples table with a version tag is listed as a separate entry for its outline.
+func scenarios(path string) (found []taggedScenario) {
+	if path == "" {
+		return
+	}
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(file, ".feature") {
+			found = append(found, scenariosInFile(file)...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[WARN] Failed to read feature files from '%s' for version tags: %v", path, err)
+	}
+	return
+}
+
+func scenariosInFile(path string) (found []taggedScenario) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("[WARN] Failed to read feature file '%s' for version tags: %v", path, err)
+		return
+	}
+	defer file.Close()
+
+	var featureTags, scenarioTags, pending []string
+	var name string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "@"):
+			for _, tag := range strings.Fields(line) {
+				if strings.HasPrefix(tag, "#") {
+					break
+				}
+				pending = append(pending, tag)
+			}
+		case strings.HasPrefix(line, "Feature:"):
+			featureTags, pending = pending, nil
+		case strings.HasPrefix(line, "Scenario:") || strings.HasPrefix(line, "Scenario Outline:") || strings.HasPrefix(line, "Scenario Template:"):
+			name = strings.TrimSpace(line[strings.Index(line, ":")+1:])
+			scenarioTags = append(append([]string{}, featureTags...), pending...)
+			pending = nil
+			found = append(found, taggedScenario{name, scenarioTags})
+		case strings.HasPrefix(line, "Examples:") || strings.HasPrefix(line, "Scenarios:"):
+			if gated(pending) {
+				found = append(found, taggedScenario{name, append(append([]string{}, scenarioTags...), pending...)})
+			}
+			pending = nil
+		}
+	}
+	return
+}
+
+// gated returns true if any of the tags is a version tag
+func gated(tags []string) bool {
+	for _, tag := range tags {
+		if versionTag.MatchString(tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package versions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/probr/probr-sdk/probeengine"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		expected Version
+		err      bool
+	}{
+		{value: "1.25", expected: Version{1, 25}},
+		{value: "v1.25.3", expected: Version{1, 25}},
+		{value: "v1.25.3-gke.100", expected: Version{1, 25}},
+		{value: "v1.9.11-eks-5876a6", expected: Version{1, 9}},
+		{value: "25+", err: true},
+		{value: "v1", err: true},
+		{value: "", err: true},
+	} {
+		v, err := Parse(test.value)
+		if (err != nil) != test.err || (!test.err && v != test.expected) {
+			t.Errorf("Parse(%q): expected %v (error %v), found %v (%v)", test.value, test.expected, test.err, v, err)
+		}
+	}
+}
+
+func TestFromInfo(t *testing.T) {
+	for _, test := range []struct {
+		info     *version.Info
+		expected Version
+		err      bool
+	}{
+		{info: &version.Info{GitVersion: "v1.25.3-gke.100", Major: "1", Minor: "25+"}, expected: Version{1, 25}},
+		{info: &version.Info{Major: "1", Minor: "25+"}, expected: Version{1, 25}},
+		{info: &version.Info{GitVersion: "unknown", Major: "1", Minor: "22"}, expected: Version{1, 22}},
+		{info: &version.Info{}, err: true},
+		{info: nil, err: true},
+	} {
+		v, err := FromInfo(test.info)
+		if (err != nil) != test.err || (!test.err && v != test.expected) {
+			t.Errorf("FromInfo(%+v): expected %v (error %v), found %v (%v)", test.info, test.expected, test.err, v, err)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	for _, test := range []struct {
+		tag     string
+		server  Version
+		applies bool
+	}{
+		{tag: "@k8s-gte-1.25", server: Version{1, 24}, applies: false},
+		{tag: "@k8s-gte-1.25", server: Version{1, 25}, applies: true},
+		{tag: "@k8s-gte-1.25", server: Version{1, 26}, applies: true},
+		{tag: "@k8s-gte-1.25", server: Version{2, 0}, applies: true},
+		{tag: "@k8s-lt-1.25", server: Version{1, 24}, applies: true},
+		{tag: "@k8s-lt-1.25", server: Version{1, 25}, applies: false},
+		{tag: "@k8s-lt-1.25", server: Version{1, 26}, applies: false},
+		{tag: "@k8s-lt-1.25", server: Version{0, 30}, applies: true},
+		{tag: "@k8s-gte-latest", server: Version{1, 20}, applies: true}, // Invalid version tags are ignored
+		{tag: "@k-pod-011", server: Version{1, 20}, applies: true},
+	} {
+		applies, reason := Applies(test.tag, test.server)
+		if applies != test.applies {
+			t.Errorf("Applies(%s, %s): expected %v, found %v (%s)", test.tag, test.server, test.applies, applies, reason)
+		}
+		if applies == (reason != "") {
+			t.Errorf("Applies(%s, %s): expected a reason only when the tag does not apply, found '%s'", test.tag, test.server, reason)
+		}
+	}
+}
+
+const gatedFeature = `@k-test
+Feature: Version gated scenarios
+
+    @k-test-001
+    Scenario: Runs on every version
+        Given a Kubernetes cluster exists which we can deploy into
+
+    @k-test-002
+    @k8s-gte-1.25
+    Scenario: Requires a recent version
+        Given a Kubernetes cluster exists which we can deploy into
+
+    @k-test-003
+    Scenario Outline: Uses a different field on each version
+        Given a Kubernetes cluster exists which we can deploy into
+
+        @k8s-lt-1.25
+        Examples:
+            | FIELD      |
+            | annotation |
+
+        @k8s-gte-1.25
+        Examples:
+            | FIELD          |
+            | seccompProfile |
+`
+
+type testProbe struct {
+	path string
+}
+
+func (p testProbe) ProbeInitialize(*godog.TestSuiteContext)   {}
+func (p testProbe) ScenarioInitialize(*godog.ScenarioContext) {}
+func (p testProbe) Name() string                              { return "test" }
+func (p testProbe) Path() string                              { return p.path }
+
+func TestGate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.feature")
+	if err = ioutil.WriteFile(path, []byte(gatedFeature), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		server     Version
+		exclusions []string
+		skipped    []string
+	}{
+		{server: Version{1, 24}, exclusions: []string{"k8s-gte-1.25"}, skipped: []string{"Requires a recent version", "Uses a different field on each version"}},
+		{server: Version{1, 25}, exclusions: []string{"k8s-lt-1.25"}, skipped: []string{"Uses a different field on each version"}},
+	} {
+		exclusions, skipped := Gate([]probeengine.Probe{testProbe{path}}, test.server)
+		if strings.Join(exclusions, ",") != strings.Join(test.exclusions, ",") {
+			t.Errorf("%s: expected exclusions %v, found %v", test.server, test.exclusions, exclusions)
+		}
+		var names []string
+		for _, scenario := range skipped {
+			names = append(names, scenario.Scenario)
+			if scenario.Probe != "test" || scenario.Reason == "" {
+				t.Errorf("%s: expected the probe and reason to be recorded, found %+v", test.server, scenario)
+			}
+		}
+		if strings.Join(names, ",") != strings.Join(test.skipped, ",") {
+			t.Errorf("%s: expected skipped scenarios %v, found %v", test.server, test.skipped, names)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package waivers provides the logic required to exclude accepted risks from the overall result of a probe run
+package waivers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	audit "github.com/probr/probr-sdk/audit"
+	sdkConfig "github.com/probr/probr-sdk/config"
+	"github.com/probr/probr-sdk/utils"
+)
+
+// ExpiryFormat is the layout expected for the Expiry value of each waiver
+const ExpiryFormat = "2006-01-02"
+
+// Waiver describes an accepted risk for one or more scenario tags
+type Waiver struct {
+	Tags          []string `yaml:"Tags"`
+	KubeContext   string   `yaml:"KubeContext"` // Optional; waiver only applies when using this kube context
+	Justification string   `yaml:"Justification"`
+	Approver      string   `yaml:"Approver"`
+	Expiry        string   `yaml:"Expiry"` // Waiver is valid up to and including this date (YYYY-MM-DD)
+}
+
+// waivedScenario is logged to the summary for each failure that has been excluded by a waiver
+type waivedScenario struct {
+	Probe         string
+	Scenario      string
+	Tags          []string
+	Justification string
+	Approver      string
+	Expiry        string
+}
+
+type waiverFile struct {
+	Waivers []Waiver `yaml:"Waivers"`
+}
+
+// Load reads and validates the waivers listed in the provided file. An empty path returns no waivers.
+func Load(path string) (waivers []Waiver, err error) {
+	if path == "" {
+		return
+	}
+	decoder, file, err := sdkConfig.NewConfigDecoder(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	// Unknown fields are rejected, so that a misspelt or unsupported scope never widens a waiver to every cluster
+	decoder.SetStrict(true)
+	var content waiverFile
+	err = decoder.Decode(&content)
+	if err != nil {
+		err = utils.ReformatError("Failed to parse waivers file '%s': %v", path, err)
+		return
+	}
+	for i, waiver := range content.Waivers {
+		if len(waiver.Tags) == 0 {
+			return nil, utils.ReformatError("Waiver %d in '%s' does not list any tags", i+1, path)
+		}
+		if waiver.Justification == "" || waiver.Approver == "" {
+			return nil, utils.ReformatError("Waiver %d in '%s' must provide a justification and an approver", i+1, path)
+		}
+		if _, parseErr := time.Parse(ExpiryFormat, waiver.Expiry); parseErr != nil {
+			return nil, utils.ReformatError("Waiver %d in '%s' has an invalid expiry date '%s'. Expected format: %s", i+1, path, waiver.Expiry, ExpiryFormat)
+		}
+	}
+	log.Printf("[DEBUG] Loaded %d waivers from %s", len(content.Waivers), path)
+	return content.Waivers, nil
+}
+
+// Expired reports whether the waiver is no longer valid at the provided time
+func (w Waiver) Expired(now time.Time) bool {
+	expiry, err := time.Parse(ExpiryFormat, w.Expiry)
+	if err != nil {
+		return true
+	}
+	return !now.UTC().Before(expiry.AddDate(0, 0, 1))
+}
+
+// Matches reports whether the waiver covers a scenario with the provided tags when using the kube context
+func (w Waiver) Matches(tags []string, kubeContext string) bool {
+	if w.KubeContext != "" && w.KubeContext != kubeContext {
+		return false
+	}
+	for _, tag := range w.Tags {
+		if _, found := utils.FindString(tags, "@"+strings.TrimPrefix(tag, "@")); found {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply marks failed scenarios covered by a valid waiver as "Waived" and updates the
+// probe and summary counters accordingly. Expired waivers are logged, but leave the failure in place.
+func Apply(state *audit.SummaryState, waivers []Waiver, kubeContext string) {
+	if len(waivers) == 0 {
+		return
+	}
+	now := time.Now()
+	var waived, expired []waivedScenario
+
+	for probeName, probe := range state.Probes {
+		var waivedCount int
+		for _, scenario := range probe.Scenarios {
+			if scenario.Result != "Failed" {
+				continue
+			}
+			for _, waiver := range waivers {
+				if !waiver.Matches(scenario.Tags, kubeContext) {
+					continue
+				}
+				entry := waivedScenario{
+					Probe:         probeName,
+					Scenario:      scenario.Name,
+					Tags:          scenario.Tags,
+					Justification: waiver.Justification,
+					Approver:      waiver.Approver,
+					Expiry:        waiver.Expiry,
+				}
+				if waiver.Expired(now) {
+					log.Printf("[WARN] Waiver for '%s' expired on %s and will not be applied", scenario.Name, waiver.Expiry)
+					expired = append(expired, entry)
+					continue
+				}
+				log.Printf("[INFO] Failure of '%s' has been waived by %s until %s", scenario.Name, waiver.Approver, waiver.Expiry)
+				scenario.Result = "Waived"
+				waived = append(waived, entry)
+				waivedCount++
+				break
+			}
+		}
+		if waivedCount == 0 {
+			continue
+		}
+		probe.ScenariosFailed = probe.ScenariosFailed - waivedCount
+		probe.Meta["scenarios_waived"] = waivedCount
+		if probe.Result == "Failed" {
+			rederive(state, probe, waivedCount)
+		}
+		probe.Write() // Overwrite the audit file written during probe completion
+	}
+
+	state.Meta["waived_scenarios"] = waived
+	if len(expired) > 0 {
+		state.Meta["expired_waivers"] = expired
+	}
+}
+
+// rederive sets the result of a failed probe once its waived scenarios are discounted, in the same way as the
+// SDK completes a probe: the probe succeeds if every remaining scenario passed, or is skipped if the Given
+// step of every remaining scenario was not met. Otherwise the probe is still failed.
+func rederive(state *audit.SummaryState, probe *audit.Probe, waivedCount int) {
+	attempted := probe.ScenariosAttempted - waivedCount
+	switch attempted {
+	case probe.ScenariosSucceeded:
+		probe.Result = "Success"
+		state.ProbesPassed = state.ProbesPassed + 1
+	case probe.GivenNotMet:
+		probe.Result = "Given was Not Met"
+		state.ProbesSkipped = state.ProbesSkipped + 1
+	default:
+		return
+	}
+	state.ProbesFailed = state.ProbesFailed - 1
+}
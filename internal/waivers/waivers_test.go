@@ -0,0 +1,201 @@
+package waivers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	messages "github.com/cucumber/messages-go/v10"
+	audit "github.com/probr/probr-sdk/audit"
+	sdkConfig "github.com/probr/probr-sdk/config"
+)
+
+const future = "2999-12-31"
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-waivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "waivers.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		waivers int
+		err     bool
+	}{
+		{
+			name:    "valid",
+			content: "Waivers:\n  - Tags: [k-gen-001]\n    KubeContext: prod\n    Justification: Required\n    Approver: approver\n    Expiry: \"2021-12-31\"\n",
+			waivers: 1,
+		},
+		{
+			name:    "no tags",
+			content: "Waivers:\n  - Justification: Required\n    Approver: approver\n    Expiry: \"2021-12-31\"\n",
+			err:     true,
+		},
+		{
+			name:    "no approver",
+			content: "Waivers:\n  - Tags: [k-gen-001]\n    Justification: Required\n    Expiry: \"2021-12-31\"\n",
+			err:     true,
+		},
+		{
+			name:    "invalid expiry",
+			content: "Waivers:\n  - Tags: [k-gen-001]\n    Justification: Required\n    Approver: approver\n    Expiry: 31/12/2021\n",
+			err:     true,
+		},
+		{
+			name:    "unsupported scope",
+			content: "Waivers:\n  - Tags: [k-gen-001]\n    Namespace: probr\n    Justification: Required\n    Approver: approver\n    Expiry: \"2021-12-31\"\n",
+			err:     true,
+		},
+	} {
+		waivers, err := Load(writeFile(t, test.content))
+		if len(waivers) != test.waivers || (err != nil) != test.err {
+			t.Errorf("%s: expected %d waivers (error %v), found %d (%v)", test.name, test.waivers, test.err, len(waivers), err)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	waiver := Waiver{Expiry: "2021-12-31"}
+	for _, test := range []struct {
+		now     time.Time
+		expired bool
+	}{
+		{now: time.Date(2021, 12, 30, 12, 0, 0, 0, time.UTC), expired: false},
+		{now: time.Date(2021, 12, 31, 23, 59, 59, 0, time.UTC), expired: false},
+		{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), expired: true},
+	} {
+		if expired := waiver.Expired(test.now); expired != test.expired {
+			t.Errorf("Expected expired to be %v at %s, found %v", test.expired, test.now, expired)
+		}
+	}
+	if !(Waiver{Expiry: "invalid"}).Expired(time.Now()) {
+		t.Error("Expected a waiver with an invalid expiry to be expired")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tags := []string{"@k-gen", "@k-gen-001", "@cis-6.10.1"}
+	for _, test := range []struct {
+		name        string
+		waiver      Waiver
+		kubeContext string
+		matches     bool
+	}{
+		{name: "tag without @", waiver: Waiver{Tags: []string{"k-gen-001"}}, kubeContext: "dev", matches: true},
+		{name: "tag with @", waiver: Waiver{Tags: []string{"@cis-6.10.1"}}, kubeContext: "dev", matches: true},
+		{name: "other tag", waiver: Waiver{Tags: []string{"k-gen-002"}}, kubeContext: "dev", matches: false},
+		{name: "partial tag", waiver: Waiver{Tags: []string{"k-gen-00"}}, kubeContext: "dev", matches: false},
+		{name: "same context", waiver: Waiver{Tags: []string{"k-gen-001"}, KubeContext: "prod"}, kubeContext: "prod", matches: true},
+		{name: "other context", waiver: Waiver{Tags: []string{"k-gen-001"}, KubeContext: "prod"}, kubeContext: "dev", matches: false},
+	} {
+		if matches := test.waiver.Matches(tags, test.kubeContext); matches != test.matches {
+			t.Errorf("%s: expected match to be %v, found %v", test.name, test.matches, matches)
+		}
+	}
+}
+
+// completedState returns a summary in which the probe has completed with the provided scenario results,
+// each tagged with its position such as @k-pod-001
+func completedState(t *testing.T, results ...string) *audit.SummaryState {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-waivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sdkConfig.GlobalConfig.WriteDirectory = dir
+	os.MkdirAll(filepath.Join(dir, "audit"), 0755)
+
+	state := audit.NewSummaryState("kubernetes")
+	probe := state.GetProbeLog("podsecurity")
+	for i, result := range results {
+		tags := []*messages.Pickle_PickleTag{{Name: "@k-pod"}, {Name: fmt.Sprintf("@k-pod-%03d", i+1)}}
+		probe.InitializeAuditor("Scenario", tags).Result = result
+	}
+	state.ProbeComplete("podsecurity")
+	return &state
+}
+
+func TestApply(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		results       []string
+		waiver        Waiver
+		probeResult   string
+		waived        int
+		passed        int
+		failed        int
+		skipped       int
+		expiredListed bool
+	}{
+		{
+			name:        "only failure waived",
+			results:     []string{"Passed", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod-002"}, Expiry: future},
+			probeResult: "Success", waived: 1, passed: 1,
+		},
+		{
+			name:        "remaining scenarios Given Not Met",
+			results:     []string{"Given Not Met", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod-002"}, Expiry: future},
+			probeResult: "Given was Not Met", waived: 1, skipped: 1,
+		},
+		{
+			name:        "another failure remains",
+			results:     []string{"Failed", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod-002"}, Expiry: future},
+			probeResult: "Failed", waived: 1, failed: 1,
+		},
+		{
+			name:        "Given Not Met is never waived",
+			results:     []string{"Passed", "Given Not Met", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod"}, Expiry: future},
+			probeResult: "Failed", waived: 1, failed: 1,
+		},
+		{
+			name:        "expired",
+			results:     []string{"Passed", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod-002"}, Expiry: "2021-12-31"},
+			probeResult: "Failed", failed: 1, expiredListed: true,
+		},
+		{
+			name:        "other context",
+			results:     []string{"Passed", "Failed"},
+			waiver:      Waiver{Tags: []string{"k-pod-002"}, KubeContext: "prod", Expiry: future},
+			probeResult: "Failed", failed: 1,
+		},
+	} {
+		state := completedState(t, test.results...)
+		Apply(state, []Waiver{test.waiver}, "dev")
+
+		probe := state.Probes["podsecurity"]
+		if probe.Result != test.probeResult {
+			t.Errorf("%s: expected probe result '%s', found '%s'", test.name, test.probeResult, probe.Result)
+		}
+		if waived := state.Meta["waived_scenarios"].([]waivedScenario); len(waived) != test.waived {
+			t.Errorf("%s: expected %d waived scenarios, found %d", test.name, test.waived, len(waived))
+		}
+		if state.ProbesPassed != test.passed || state.ProbesFailed != test.failed || state.ProbesSkipped != test.skipped {
+			t.Errorf("%s: expected %d/%d/%d probes passed/failed/skipped, found %d/%d/%d", test.name,
+				test.passed, test.failed, test.skipped, state.ProbesPassed, state.ProbesFailed, state.ProbesSkipped)
+		}
+		if _, found := state.Meta["expired_waivers"]; found != test.expiredListed {
+			t.Errorf("%s: expected expired waivers to be listed: %v", test.name, test.expiredListed)
+		}
+	}
+}
@@ -7,11 +7,23 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/probr/probr-pack-kubernetes/internal/config"
 	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/diff"
+	"github.com/probr/probr-pack-kubernetes/internal/fleet"
+	"github.com/probr/probr-pack-kubernetes/internal/general"
+	"github.com/probr/probr-pack-kubernetes/internal/metrics"
+	"github.com/probr/probr-pack-kubernetes/internal/policyreport"
+	"github.com/probr/probr-pack-kubernetes/internal/rbac"
+	"github.com/probr/probr-pack-kubernetes/internal/severity"
 	"github.com/probr/probr-pack-kubernetes/internal/summary"
+	"github.com/probr/probr-pack-kubernetes/internal/versions"
+	"github.com/probr/probr-pack-kubernetes/internal/waivers"
 	"github.com/probr/probr-pack-kubernetes/pack"
 
 	audit "github.com/probr/probr-sdk/audit"
@@ -43,6 +55,9 @@ var (
 	// BuiltAt is the build date
 	// This should only be modified thru ldflags in make file. See 'make release'
 	BuiltAt = ""
+
+	// rbacNamespace is the namespace used for the service account in the generated RBAC manifest
+	rbacNamespace string
 )
 
 // ServicePack ...
@@ -56,11 +71,11 @@ func (sp *ServicePack) RunProbes() error {
 
 // main is executed when this file is called as a binary or `go run`
 func main() {
-	versionCmd, runCmd := setFlags()
-	handleCommands(versionCmd, runCmd)
+	versionCmd, runCmd, diffCmd, rbacCmd := setFlags()
+	handleCommands(versionCmd, runCmd, diffCmd, rbacCmd)
 }
 
-func setFlags() (versionCmd, runCmd *flag.FlagSet) {
+func setFlags() (versionCmd, runCmd, diffCmd, rbacCmd *flag.FlagSet) {
 	// > probr version [-v]
 	versionCmd = flag.NewFlagSet("version", flag.ExitOnError)
 	config.Vars.Verbose = *versionCmd.Bool("v", false, "Display extended version information") // TODO: Harness '-v' in the standard probr execution
@@ -69,10 +84,17 @@ func setFlags() (versionCmd, runCmd *flag.FlagSet) {
 	runCmd = flag.NewFlagSet("run", flag.ExitOnError)
 	runCmd.StringVar(&config.Vars.VarsFile, "varsfile", "", "path to config file")
 	runCmd.StringVar(&config.Vars.ServicePacks.Kubernetes.KubeConfigPath, "kubeconfig", "", "kube config file")
+
+	// > probr diff <previous output dir> <current output dir>
+	diffCmd = flag.NewFlagSet("diff", flag.ExitOnError)
+
+	// > probr rbac [-namespace]
+	rbacCmd = flag.NewFlagSet("rbac", flag.ExitOnError)
+	rbacCmd.StringVar(&rbacNamespace, "namespace", "probr", "namespace for the service account the pack runs as")
 	return
 }
 
-func handleCommands(versionCmd, runCmd *flag.FlagSet) {
+func handleCommands(versionCmd, runCmd, diffCmd, rbacCmd *flag.FlagSet) {
 	subCommand := ""
 	if len(os.Args) > 1 {
 		subCommand = os.Args[1]
@@ -82,11 +104,26 @@ func handleCommands(versionCmd, runCmd *flag.FlagSet) {
 		versionCmd.Parse(os.Args[2:])
 		printVersion(os.Stdout)
 
+	case "diff":
+		diffCmd.Parse(os.Args[2:])
+		os.Exit(runDiff(os.Stdout, diffCmd.Args()))
+
+	case "rbac":
+		rbacCmd.Parse(os.Args[2:])
+		printRBACManifest(os.Stdout)
+
 	case "debug": // Same cli args as run. Use this to bypass plugin and execute directly for debugging
 		// Parse cli args
 		runCmd.Parse(os.Args[2:]) // Skip first arg as it will be 'debug'
 		ProbrCoreLogic()
 
+	case "daemon": // Same cli args as run. Continuously executes probes and serves metrics until the process is stopped
+		runCmd.Parse(os.Args[2:])
+		err := ProbrDaemon()
+		if err != nil {
+			os.Exit(1)
+		}
+
 	default:
 		// Parse cli args
 		runCmd.Parse(os.Args[1:])
@@ -123,30 +160,273 @@ func ProbrCoreLogic() (err error) {
 	setupCloseHandler() // Sigterm protection
 
 	config.Vars.Init()
-	summary.State = audit.NewSummaryState(ServicePackName)
 
+	if _, err = severity.Rank(config.Vars.ServicePacks.Kubernetes.FailOnSeverity); err != nil {
+		log.Printf("[ERROR] Invalid FailOnSeverity: %v", err)
+		return
+	}
+	waiverList, err := waivers.Load(config.Vars.ServicePacks.Kubernetes.WaiversPath)
+	if err != nil {
+		log.Printf("[ERROR] Error loading waivers: %v", err)
+		return
+	}
+
+	targets, err := connection.Targets()
+	if err != nil {
+		log.Printf("[ERROR] Error reading cluster targets: %v", err)
+		return
+	}
+	if len(targets) == 1 {
+		targets[0].Use()
+		connection.Connect()
+		return runProbes(waiverList, config.Vars.ServicePacks.Kubernetes.BaselinePath)
+	}
+	return runFleet(targets, waiverList)
+}
+
+// runFleet executes all probes once per target cluster, writing each cluster's output to its own
+// subdirectory and a roll-up of the failed controls to the main output directory
+func runFleet(targets []connection.Target, waiverList []waivers.Waiver) error {
+	outputDir := sdkConfig.GlobalConfig.WriteDirectory
+	rollup := fleet.NewRollup()
+
+	for _, target := range targets {
+		log.Printf("[INFO] Running probes against cluster '%s'", target.Name)
+		target.Use()
+		clusterDir := filepath.Join(outputDir, "clusters", target.Name)
+		sdkConfig.GlobalConfig.WriteDirectory = clusterDir
+		sdkConfig.GlobalConfig.PrepareOutputDirectory("audit", "cucumber")
+
+		baseline := config.Vars.ServicePacks.Kubernetes.BaselinePath
+		if baseline != "" {
+			baseline = filepath.Join(baseline, "clusters", target.Name)
+		}
+		fixturePath := config.Vars.ServicePacks.Kubernetes.FixturePath
+		if mode := config.Vars.ServicePacks.Kubernetes.ConnectionMode; mode == connection.RecordMode || mode == connection.ReplayMode {
+			config.Vars.ServicePacks.Kubernetes.FixturePath = strings.TrimSuffix(fixturePath, ".json") + "-" + target.Name + ".json"
+		}
+		connection.Connect()
+		runErr := runProbes(waiverList, baseline)
+		config.Vars.ServicePacks.Kubernetes.FixturePath = fixturePath
+		if runErr != nil {
+			log.Printf("[ERROR] Cluster '%s': %v", target.Name, runErr)
+		}
+		rollup.Add(target.Name, clusterDir, &summary.State, runErr)
+	}
+	sdkConfig.GlobalConfig.WriteDirectory = outputDir
+
+	rollup.Print(log.Writer())
+	err := rollup.Write(filepath.Join(outputDir, "fleet.json"))
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+	if rollup.Failed() {
+		return utils.ReformatError("One or more clusters were not successful. View %s for more details.", filepath.Join(outputDir, "fleet.json"))
+	}
+	return nil
+}
+
+// ProbrDaemon connects to the configured cluster once, then executes all probes on the configured interval
+// while serving the results of the latest run as Prometheus metrics
+func ProbrDaemon() (err error) {
+	defer sdkConfig.GlobalConfig.CleanupTmp()
+	setupCloseHandler() // Sigterm protection
+
+	config.Vars.Init()
+
+	if _, err = severity.Rank(config.Vars.ServicePacks.Kubernetes.FailOnSeverity); err != nil {
+		log.Printf("[ERROR] Invalid FailOnSeverity: %v", err)
+		return
+	}
+	interval, err := time.ParseDuration(config.Vars.ServicePacks.Kubernetes.RunInterval)
+	if err != nil {
+		log.Printf("[ERROR] Invalid run interval '%s': %v", config.Vars.ServicePacks.Kubernetes.RunInterval, err)
+		return
+	}
+	waiverList, err := waivers.Load(config.Vars.ServicePacks.Kubernetes.WaiversPath)
+	if err != nil {
+		log.Printf("[ERROR] Error loading waivers: %v", err)
+		return
+	}
+	targets, err := connection.Targets()
+	if err != nil {
+		log.Printf("[ERROR] Error reading cluster targets: %v", err)
+		return
+	}
+	if len(targets) > 1 {
+		err = utils.ReformatError("Continuous mode supports a single cluster, but %d were configured by KubeContexts or KubeConfigDir", len(targets))
+		log.Print(err)
+		return
+	}
+
+	targets[0].Use()
 	connection.Connect()
+	go metrics.Serve(config.Vars.ServicePacks.Kubernetes.MetricsAddress, interval)
+
+	// Runs are executed sequentially from this loop, so they can never overlap. If a run
+	// takes longer than the interval, the next run starts as soon as it has completed.
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		metrics.RecordStart()
+		runErr := runProbes(waiverList, config.Vars.ServicePacks.Kubernetes.BaselinePath)
+		if runErr != nil {
+			log.Printf("[ERROR] %v", runErr)
+		}
+		metrics.RecordRun(&summary.State, time.Since(start), runErr)
+		log.Printf("[INFO] Next run scheduled in %s", interval)
+		<-ticker.C
+	}
+}
+
+// runProbes executes all probes against the cluster that is currently connected
+func runProbes(waiverList []waivers.Waiver, baseline string) (err error) {
+	summary.State = audit.NewSummaryState(ServicePackName)
+	connection.RewindReplay() // Each run in continuous mode replays the fixture from the start
 
-	store := probeengine.NewProbeStore(ServicePackName, config.Vars.Tags(), &summary.State)
-	s, err := store.RunAllProbes(pack.GetProbes())
+	probes := pack.GetProbes()
+	store := probeengine.NewProbeStore(ServicePackName, scenarioTags(probes), &summary.State)
+	s, err := store.RunAllProbes(probes)
 	if err != nil {
 		log.Printf("[ERROR] Error executing tests %v", err)
 		return
 	}
 	log.Printf("[INFO] Overall test completion status: %v", s)
+	connection.SaveRecording(config.Vars.ServicePacks.Kubernetes.FixturePath)
+	return completeRun(waiverList, baseline)
+}
+
+// completeRun derives the overall result of the probes that have run, writes the summary, and returns an error
+// if the run was not successful
+func completeRun(waiverList []waivers.Waiver, baseline string) (err error) {
+	summary.CountInconclusive(&summary.State)
+	waivers.Apply(&summary.State, waiverList, config.Vars.ServicePacks.Kubernetes.KubeContext)
+	threshold := config.Vars.ServicePacks.Kubernetes.FailOnSeverity
+	blockingFailures, err := severity.Evaluate(&summary.State, threshold)
+	if err != nil {
+		log.Printf("[ERROR] Error evaluating scenario severities: %v", err)
+		return
+	}
 	summary.State.SetProbrStatus()
+	var baselineErr error
+	if baseline != "" {
+		// Failures that were already present in the baseline are accepted, so only new failures can block the run
+		blockingFailures, baselineErr = compareToBaseline(baseline, threshold)
+	}
+	publishPolicyReports()
 
 	summary.State.PrintSummary()
 	summary.State.WriteSummary()
 
+	if baselineErr != nil {
+		return baselineErr
+	}
 	if summary.State.ProbesPassed == 0 && summary.State.ProbesFailed == 0 {
 		return utils.ReformatError("No probes ran, or all probes given statements were not met.")
-	} else if summary.State.ProbesFailed > 0 {
-		return utils.ReformatError("One or more probe scenarios were not successful. View the output logs for more details.")
+	} else if blockingFailures > 0 && baseline != "" {
+		return utils.ReformatError("%d scenarios at or above '%s' severity regressed since the baseline run at %s. View %s for more details.",
+			blockingFailures, threshold, baseline, filepath.Join(sdkConfig.GlobalConfig.WriteDirectory, "diff.json"))
+	} else if blockingFailures > 0 {
+		return utils.ReformatError("One or more probe scenarios at or above '%s' severity were not successful. View the output logs for more details.", threshold)
 	}
 	return
 }
 
+// runDiff compares two audit output directories and returns a non-zero exit code if any regressions were found
+func runDiff(w io.Writer, args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(w, "Usage: diff <previous output directory> <current output directory>")
+		return 2
+	}
+	report, err := diff.Compare(args[0], args[1])
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 2
+	}
+	report.Print(w)
+	if report.HasRegressions() {
+		return 1
+	}
+	return 0
+}
+
+// compareToBaseline writes a diff report against the provided baseline output directory, and returns the number
+// of scenarios at or above the severity threshold that failed in this run, but did not fail in the baseline run
+func compareToBaseline(baseline, threshold string) (blocking int, err error) {
+	report, err := diff.Compare(baseline, sdkConfig.GlobalConfig.WriteDirectory)
+	if err != nil {
+		err = utils.ReformatError("Failed to compare results to baseline: %v", err)
+		return
+	}
+	writeErr := report.Write(filepath.Join(sdkConfig.GlobalConfig.WriteDirectory, "diff.json"))
+	if writeErr != nil {
+		log.Printf("[ERROR] %v", writeErr)
+	}
+	minimum, _ := severity.Rank(threshold) // Validated by severity.Evaluate
+	for _, regression := range report.Regressions {
+		if rank, _ := severity.Rank(severity.FromTags(regression.Tags)); rank >= minimum {
+			blocking++
+		}
+	}
+	summary.State.Meta["baseline_regressions"] = len(report.Regressions)
+	summary.State.Meta["baseline_fixed"] = len(report.Fixed)
+	if len(report.Regressions) > 0 {
+		log.Printf("[WARN] %d scenarios regressed since the baseline run at %s", len(report.Regressions), baseline)
+	}
+	return
+}
+
+// printRBACManifest writes the manifest required to run all probes from within the cluster
+func printRBACManifest(w io.Writer) {
+	manifest, err := rbac.Manifest(rbacNamespace)
+	if err != nil {
+		log.Fatalf("[ERROR] Failed to generate RBAC manifest: %v", err)
+	}
+	w.Write(manifest)
+}
+
+// scenarioTags returns the configured tags, excluding the scenarios whose version tags do not match the connected
+// cluster, and those that require an EgressProxy when none is configured. The cluster version and the scenarios
+// skipped by version are recorded in the summary.
+func scenarioTags(probes []probeengine.Probe) string {
+	var exclusions []string
+	server, err := versions.FromInfo(connection.Version)
+	if err != nil {
+		summary.State.Meta["kubernetes_version"] = "unknown"
+	} else {
+		summary.State.Meta["kubernetes_version"] = server.String()
+		var skipped []versions.SkippedScenario
+		exclusions, skipped = versions.Gate(probes, server)
+		if len(skipped) > 0 {
+			summary.State.Meta["version_skipped_scenarios"] = skipped
+		}
+	}
+	if config.Vars.ServicePacks.Kubernetes.EgressProxy == "" {
+		log.Printf("[INFO] Skipping scenarios tagged %s, as no EgressProxy is configured", general.EgressProxyTag)
+		exclusions = append(exclusions, strings.TrimPrefix(general.EgressProxyTag, "@"))
+	}
+	if len(exclusions) == 0 {
+		return config.Vars.Tags()
+	}
+	return sdkConfig.ParseTags(config.Vars.ServicePacks.Kubernetes.TagInclusions,
+		append(append([]string{}, config.Vars.ServicePacks.Kubernetes.TagExclusions...), exclusions...))
+}
+
+// publishPolicyReports writes the scenario results to the cluster as PolicyReport objects, if enabled
+func publishPolicyReports() {
+	if config.Vars.ServicePacks.Kubernetes.PolicyReports != "true" {
+		return
+	}
+	client, err := connection.DynamicClient()
+	if err == nil {
+		err = policyreport.Publish(client, &summary.State, config.Vars.ServicePacks.Kubernetes.ProbeNamespace)
+	}
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+	}
+}
+
 func printVersion(w io.Writer) {
 
 	if config.Vars.Verbose {
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/probr/probr-pack-kubernetes/internal/config"
+	"github.com/probr/probr-pack-kubernetes/internal/connection"
+	"github.com/probr/probr-pack-kubernetes/internal/connection/fake"
+	"github.com/probr/probr-pack-kubernetes/internal/general"
+	"github.com/probr/probr-pack-kubernetes/internal/probetest"
+	"github.com/probr/probr-pack-kubernetes/internal/rbac"
+	"github.com/probr/probr-pack-kubernetes/internal/summary"
+	"github.com/probr/probr-pack-kubernetes/pack"
+	audit "github.com/probr/probr-sdk/audit"
+	"github.com/probr/probr-sdk/utils"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRBACManifestIsUpToDate(t *testing.T) {
+	rbacNamespace = "probr"
+	var generated bytes.Buffer
+	printRBACManifest(&generated)
+
+	committed, err := ioutil.ReadFile("deploy/rbac.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, generated.Bytes()) {
+		t.Errorf("deploy/rbac.yaml is out of date with rbac.Probes. Run 'make rbac-manifest' to regenerate it.\nExpected:\n%s", generated.String())
+	}
+}
+
+func TestRBACPermissionsListedForEveryProbe(t *testing.T) {
+	for _, probe := range pack.GetProbes() {
+		if _, found := rbac.Probes[probe.Name()]; !found {
+			t.Errorf("Probe '%s' has no permissions listed in rbac.Probes", probe.Name())
+		}
+	}
+}
+
+func TestRBACPermissionsListedForEveryConnectionMethod(t *testing.T) {
+	methods := reflect.TypeOf((*connection.Connection)(nil)).Elem()
+	for i := 0; i < methods.NumMethod(); i++ {
+		if _, found := rbac.Methods[methods.Method(i).Name]; !found {
+			t.Errorf("Connection method '%s' has no permissions listed in rbac.Methods", methods.Method(i).Name)
+		}
+	}
+}
+
+// TestRBACPermissionsCoverRecordedInteractions runs every scenario of each probe against a fake cluster, and checks
+// that the permissions used by each recorded interaction are listed for the probe and granted by deploy/rbac.yaml
+func TestRBACPermissionsCoverRecordedInteractions(t *testing.T) {
+	committed, err := ioutil.ReadFile("deploy/rbac.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clusterRole rbacv1.ClusterRole
+	if err = yaml.Unmarshal(bytes.Split(committed, []byte("---\n"))[0], &clusterRole); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, probe := range pack.GetProbes() {
+		recorder := connection.NewRecorder(fake.NewCluster())
+		probetest.Run(t, probe, recorder, "")
+
+		listed := append(append([]rbac.Permission(nil), rbac.Steps...), rbac.Probes[probe.Name()]...)
+		checked := make(map[string]bool)
+		for _, interaction := range recorder.Interactions() {
+			if checked[interaction.Method] {
+				continue
+			}
+			checked[interaction.Method] = true
+			for _, permission := range rbac.Methods[interaction.Method] {
+				for _, verb := range permission.Verbs {
+					if !permitted(listed, permission.APIGroup, permission.Resource, verb) {
+						t.Errorf("Probe '%s' calls %s, which needs '%s' on '%s', but it is not listed in rbac.Probes",
+							probe.Name(), interaction.Method, verb, permission.Resource)
+					}
+					if !granted(clusterRole.Rules, permission.APIGroup, permission.Resource, verb) {
+						t.Errorf("Probe '%s' calls %s, which needs '%s' on '%s', but it is not granted by deploy/rbac.yaml",
+							probe.Name(), interaction.Method, verb, permission.Resource)
+					}
+				}
+			}
+		}
+	}
+}
+
+func permitted(permissions []rbac.Permission, apiGroup, resource, verb string) bool {
+	for _, p := range permissions {
+		if _, found := utils.FindString(p.Verbs, verb); found && p.APIGroup == apiGroup && p.Resource == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func granted(rules []rbacv1.PolicyRule, apiGroup, resource, verb string) bool {
+	for _, rule := range rules {
+		_, groupFound := utils.FindString(rule.APIGroups, apiGroup)
+		_, resourceFound := utils.FindString(rule.Resources, resource)
+		_, verbFound := utils.FindString(rule.Verbs, verb)
+		if groupFound && resourceFound && verbFound {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEgressProxyScenariosExcludedWithoutProxy(t *testing.T) {
+	summary.State = audit.NewSummaryState(ServicePackName)
+	k8s := &config.Vars.ServicePacks.Kubernetes
+	defer func(proxy string) { k8s.EgressProxy = proxy }(k8s.EgressProxy)
+
+	k8s.EgressProxy = ""
+	if tags := scenarioTags(pack.GetProbes()); !strings.Contains(tags, "~"+general.EgressProxyTag) {
+		t.Errorf("Expected scenarios tagged %s to be excluded without an EgressProxy, found tags '%s'", general.EgressProxyTag, tags)
+	}
+	k8s.EgressProxy = "http://proxy.example.com:3128"
+	if tags := scenarioTags(pack.GetProbes()); strings.Contains(tags, general.EgressProxyTag) {
+		t.Errorf("Expected scenarios tagged %s to run with an EgressProxy, found tags '%s'", general.EgressProxyTag, tags)
+	}
+}
+
+func TestInconclusiveScenariosNeitherPassNorFail(t *testing.T) {
+	// Without an EgressProxy, the proxy scenarios are inconclusive, while the Web UI scenario passes on an empty cluster
+	for _, test := range []struct {
+		tags            string
+		probeResult     string
+		passed, skipped int
+		exitErr         bool
+	}{
+		{tags: "@k-gen-001,@k-gen-006", probeResult: "Success", passed: 1},
+		{tags: "@k-gen-006", probeResult: summary.InconclusiveResult, skipped: 1, exitErr: true},
+	} {
+		probe := probetest.Run(t, general.Probe, fake.NewCluster(), test.tags)
+		err := completeRun(nil, "")
+
+		if probe.Result != test.probeResult {
+			t.Errorf("%s: expected probe result '%s', found '%s'", test.tags, test.probeResult, probe.Result)
+		}
+		if summary.State.ProbesPassed != test.passed || summary.State.ProbesSkipped != test.skipped || summary.State.ProbesFailed != 0 {
+			t.Errorf("%s: expected %d probes passed, %d skipped and none failed, found %d passed, %d skipped and %d failed", test.tags,
+				test.passed, test.skipped, summary.State.ProbesPassed, summary.State.ProbesSkipped, summary.State.ProbesFailed)
+		}
+		if (err != nil) != test.exitErr {
+			t.Errorf("%s: expected exit error %v, found: %v", test.tags, test.exitErr, err)
+		}
+	}
+}
+
+// writeBaseline writes a baseline output directory containing the scenarios of the probe that has just run,
+// each with the provided result
+func writeBaseline(t *testing.T, probeName, result string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "probr-baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.MkdirAll(filepath.Join(dir, "audit"), 0755)
+
+	scenarios := make(map[int]audit.Scenario)
+	for i, scenario := range summary.State.Probes[probeName].Scenarios {
+		scenarios[i] = audit.Scenario{Name: scenario.Name, Result: result, Tags: scenario.Tags}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"Scenarios": scenarios})
+	if err = ioutil.WriteFile(filepath.Join(dir, "audit", probeName+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestBaselineOnlyAcceptsKnownFailures(t *testing.T) {
+	// The default namespace scenario is tagged @severity-low, and fails on an empty cluster as the pod is admitted
+	k8s := &config.Vars.ServicePacks.Kubernetes
+	defer func(threshold string) { k8s.FailOnSeverity = threshold }(k8s.FailOnSeverity)
+
+	for _, test := range []struct {
+		name           string
+		tags           string
+		baselineResult string
+		threshold      string
+		exitErr        bool
+	}{
+		{name: "failure in baseline", tags: "@k-gen-010", baselineResult: "Failed", threshold: "low"},
+		{name: "passed in baseline", tags: "@k-gen-010", baselineResult: "Passed", threshold: "low", exitErr: true},
+		{name: "Given Not Met in baseline", tags: "@k-gen-010", baselineResult: "Given Not Met", threshold: "low", exitErr: true},
+		{name: "regression below FailOnSeverity", tags: "@k-gen-010", baselineResult: "Passed", threshold: "medium"},
+		{name: "no probes ran", tags: "@k-gen-006", baselineResult: "Inconclusive", threshold: "low", exitErr: true},
+	} {
+		k8s.FailOnSeverity = test.threshold
+		probetest.Run(t, general.Probe, fake.NewCluster(), test.tags)
+		err := completeRun(nil, writeBaseline(t, general.Probe.Name(), test.baselineResult))
+		if (err != nil) != test.exitErr {
+			t.Errorf("%s: expected exit error %v, found: %v", test.name, test.exitErr, err)
+		}
+	}
+}
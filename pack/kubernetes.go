@@ -2,19 +2,27 @@ package pack
 
 import (
 	"github.com/markbates/pkger"
+	"github.com/probr/probr-pack-kubernetes/internal/config"
 	cra "github.com/probr/probr-pack-kubernetes/internal/container_registry_access"
+	"github.com/probr/probr-pack-kubernetes/internal/custom"
 	"github.com/probr/probr-pack-kubernetes/internal/general"
+	psa "github.com/probr/probr-pack-kubernetes/internal/pod_security_admission"
 	"github.com/probr/probr-pack-kubernetes/internal/podsecurity"
 	"github.com/probr/probr-sdk/probeengine"
 )
 
 // GetProbes returns a list of probe objects
 func GetProbes() []probeengine.Probe {
-	return []probeengine.Probe{
+	probes := []probeengine.Probe{
 		cra.Probe,
 		general.Probe,
 		podsecurity.Probe,
+		psa.Probe,
 	}
+	if config.Vars.ServicePacks.Kubernetes.CustomFeaturesDir != "" {
+		probes = append(probes, custom.Probe)
+	}
+	return probes
 }
 
 func init() {
@@ -22,4 +30,5 @@ func init() {
 	pkger.Include("/internal/container_registry_access/container_registry_access.feature")
 	pkger.Include("/internal/general/general.feature")
 	pkger.Include("/internal/podsecurity/podsecurity.feature")
+	pkger.Include("/internal/pod_security_admission/pod_security_admission.feature")
 }